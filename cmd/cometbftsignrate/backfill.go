@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cometbftsignrate/internal/chaindata"
+	"cometbftsignrate/internal/db_utils"
+)
+
+// runBackfill stores the heights between --from and --to of one chain, it can run while the daemon is live
+func runBackfill(args []string) int {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configFile := flags.String("config", "./config.toml", "Path to the config file")
+	chainID := flags.String("chain", "", "chain_id to backfill")
+	from := flags.Int("from", 0, "First height to backfill")
+	to := flags.Int("to", 0, "Last height to backfill")
+	rpc := flags.String("rpc", "", "RPC endpoint to fetch from, defaults to archive_host or the hosts of the chain")
+	rateLimit := flags.Float64("rate-limit", -1, "Max requests per second to each RPC endpoint, defaults to rate_limit of the chain")
+	pending := flags.Bool("pending", false, "Resume the unfinished backfills recorded in the DB instead of --from and --to")
+	flags.Parse(args)
+
+	if *chainID == "" || (!*pending && (*from <= 0 || *to < *from)) {
+		fmt.Fprintln(os.Stderr, "backfill requires --chain, and --from and --to with from <= to or --pending")
+		flags.Usage()
+		return 2
+	}
+
+	config, db, err := openConfigAndDB(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db_utils.CloseDB(db)
+
+	chains, err := selectChains(config, *chainID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	chainConfig := chains[0]
+
+	// an archive node serves heights the live nodes have pruned
+	host := *rpc
+	if host == "" {
+		host = chainConfig.ArchiveHost
+	}
+	if host != "" {
+		chainConfig.HostAddress = host
+		chainConfig.Hosts = nil
+	}
+	if *rateLimit >= 0 {
+		chainConfig.RateLimit = *rateLimit
+	}
+
+	chain, err := chaindata.NewChain(chainConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if chain.PruningEnabled && !*pending {
+		_, latest, err := db.GetStoredHeightRange(chain.ChainID)
+		if err == nil && latest > 0 && *from <= latest-chain.SigningWindow {
+			fmt.Fprintf(os.Stderr, "warning: pruning is enabled, heights below %d will be pruned by the daemon\n", latest-chain.SigningWindow+1)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if *pending {
+		if err := chaindata.RunPendingBackfills(ctx, chain, db, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Printf("%s: pending backfills stopped, run the same command again to resume\n", chain.ChainID)
+			return 1
+		}
+		fmt.Printf("%s: pending backfills complete\n", chain.ChainID)
+		return 0
+	}
+
+	checkpoint, err := chaindata.Backfill(ctx, chain, db, *from, *to)
+	if checkpoint.Done() {
+		fmt.Printf("%s: backfill of %d-%d complete\n", chain.ChainID, *from, *to)
+		return 0
+	}
+	fmt.Printf("%s: backfill of %d-%d stopped at height %d, run the same command again to resume\n", chain.ChainID, *from, *to, checkpoint.NextHeight)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return 1
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+)
+
+// subcommands run once against the DB of the config and exit with the returned code
+var subcommands = map[string]func(args []string) int{
+	"repair":     runRepair,
+	"backfill":   runBackfill,
+	"migrations": runMigrations,
+}
+
+// openConfigAndDB parses the config file and opens its DB
+func openConfigAndDB(configFile string) (*config_utils.Config, db_utils.Store, error) {
+	config, err := config_utils.ParseConfig(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+	config_utils.SetChains(config)
+
+	// the daemon would overwrite the snapshot a command wrote to on its next shutdown
+	if db_utils.IsMemoryURL(config.GlobalConfig.DbURL) {
+		return nil, nil, fmt.Errorf("the in-memory store only lives in the daemon, run the command against a SQLite or PostgreSQL DB")
+	}
+
+	db, err := db_utils.InitDB(config.GlobalConfig.DbURL, config.GlobalConfig.DbLocation, config.SigningWindows())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing DB: %v", err)
+	}
+	return config, db, nil
+}
+
+// selectChains returns the chains of config matching chainID, every chain if chainID is empty
+func selectChains(config *config_utils.Config, chainID string) ([]config_utils.ChainConfig, error) {
+	if chainID == "" {
+		return config.Chains, nil
+	}
+	for _, chain := range config.Chains {
+		if chain.ChainID == chainID {
+			return []config_utils.ChainConfig{chain}, nil
+		}
+	}
+	return nil, fmt.Errorf("chain_id %s not found in config", chainID)
+}
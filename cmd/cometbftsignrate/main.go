@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"flag"
 	"fmt"
 	"log"
@@ -10,7 +9,6 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"sync"
 	"syscall"
 	"time"
 
@@ -19,18 +17,26 @@ import (
 	"cometbftsignrate/internal/config_utils"
 	"cometbftsignrate/internal/db_utils"
 	"cometbftsignrate/internal/logger"
+	"cometbftsignrate/internal/supervisor"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type App struct {
-	DB *sql.DB
+	DB db_utils.Store
 }
 
 func main() {
 	// Remove default timestamp from logs
 	log.SetFlags(0)
 
+	// Subcommands run once against the DB and exit
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	logger.PostLog("INFO", "Starting CometBFT signatures service...")
 
 	// Define a cli flag for the config file location
@@ -49,8 +55,8 @@ func main() {
 
 	config_utils.SetChains(config)
 
-	// Initialize the SQLite DB
-	db, err := db_utils.InitDB(config.GlobalConfig.DbLocation)
+	// Initialize the DB, the backend of db_url and SQLite if it is not set
+	db, err := db_utils.InitDB(config.GlobalConfig.DbURL, config.GlobalConfig.DbLocation, config.SigningWindows())
 	if err != nil {
 		logger.PostLog("ERROR", fmt.Sprintf("Error initializing DB: %v", err))
 		os.Exit(1)
@@ -67,36 +73,49 @@ func main() {
 	signal.Notify(stopImmediate, os.Interrupt)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
 
-	// Process each chain in a separate goroutine for parallel processing
+	// Every chain gets its own ingest and metrics worker (and a reconciler with a REST endpoint), the supervisor restarts them
+	// on failure so one chain can not take the others down
+	sup := supervisor.New()
 	for _, chainConfig := range config.Chains {
-		chain := chaindata.Chain(chainConfig)
-		wg.Add(2)
-
-		go func(c chaindata.Chain) {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					api.StartMetricsUpdater(db, chain.ChainID)
-				}
-			}
-		}(chain)
-		go func(c chaindata.Chain) {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					chaindata.ProcessChain(c, db, config.GlobalConfig.InitialScan, config.GlobalConfig.RestPeriod)
-					time.Sleep(time.Duration(config.GlobalConfig.RestPeriod) * time.Second)
+		chain, err := chaindata.NewChain(chainConfig)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainConfig.ChainID, Operation: "NewChain", Success: false, Message: fmt.Sprintf("Not starting chain: %v", err)})
+			continue
+		}
+
+		sup.Start(ctx, supervisor.Worker{
+			ChainID: chain.ChainID,
+			Name:    "metrics",
+			Run: func(ctx context.Context) error {
+				return api.StartMetricsUpdater(ctx, db, chain.ChainID)
+			},
+		})
+		if chainConfig.RestAddress != "" {
+			sup.Start(ctx, supervisor.Worker{
+				ChainID: chain.ChainID,
+				Name:    "reconcile",
+				Run: func(ctx context.Context) error {
+					err := api.StartReconciler(ctx, db, chain.ChainID)
+					if api.IsFatal(err) {
+						return supervisor.Permanent(err)
+					}
+					return err
+				},
+			})
+		}
+		sup.Start(ctx, supervisor.Worker{
+			ChainID: chain.ChainID,
+			Name:    "ingest",
+			Run: func(ctx context.Context) error {
+				err := chaindata.ProcessChain(ctx, chain, db, config.GlobalConfig.InitialScan, config.GlobalConfig.RestPeriod)
+				if api.IsFatal(err) {
+					// only config errors end up here, the other chains keep running
+					return supervisor.Permanent(err)
 				}
-			}
-		}(chain)
+				return err
+			},
+		})
 	}
 
 	// Set up the HTTP server
@@ -112,8 +131,25 @@ func main() {
 	mux.HandleFunc("/signrate", func(w http.ResponseWriter, r *http.Request) {
 		api.APIHandler(db, w, r)
 	})
+	mux.HandleFunc("/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		api.LeaderboardHandler(db, w, r)
+	})
+	mux.HandleFunc("/votingpower", func(w http.ResponseWriter, r *http.Request) {
+		api.VotingPowerHandler(db, w, r)
+	})
+	mux.HandleFunc("/valsetchanges", func(w http.ResponseWriter, r *http.Request) {
+		api.ValidatorSetChangesHandler(db, w, r)
+	})
+	mux.HandleFunc("/incidents", func(w http.ResponseWriter, r *http.Request) {
+		api.IncidentsHandler(db, w, r)
+	})
+	mux.HandleFunc("/gaps", func(w http.ResponseWriter, r *http.Request) {
+		api.GapsHandler(db, w, r)
+	})
 	// add prom metrics endpoint - dont need the wrapper around MetricsHandler
 	mux.Handle("/metrics", promhttp.HandlerFor(customRegistry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/workers", sup.StatusHandler)
+	mux.HandleFunc("/nodes", api.NodeHealthHandler)
 
 	srv := &http.Server{
 		Addr:         ":" + strconv.Itoa(config.GlobalConfig.HttpPort),
@@ -144,10 +180,10 @@ func main() {
 
 		cancel() // Cancel context for goroutines
 
-		// Wait for goroutines
+		// Wait for workers
 		done := make(chan struct{})
 		go func() {
-			wg.Wait()
+			sup.Wait()
 			close(done)
 		}()
 
@@ -166,7 +202,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Close the SQLite DB
+	// Close the DB
 	db_utils.CloseDB(db)
 	logger.PostLog("INFO", "Shutdown complete")
 }
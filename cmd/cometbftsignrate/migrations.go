@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+)
+
+// runMigrations lists the applied and pending schema migrations of the DB, and applies the pending ones with --apply
+func runMigrations(args []string) int {
+	flags := flag.NewFlagSet("migrations", flag.ExitOnError)
+	configFile := flags.String("config", "./config.toml", "Path to the config file")
+	apply := flags.Bool("apply", false, "Apply the pending migrations")
+	flags.Parse(args)
+
+	config, err := config_utils.ParseConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error parsing config file: %v\n", err)
+		return 1
+	}
+
+	if db_utils.IsMemoryURL(config.GlobalConfig.DbURL) {
+		fmt.Println("the in-memory store has no schema to migrate")
+		return 0
+	}
+
+	// opened without migrating, so the status is shown as found
+	db, err := db_utils.OpenDB(config.GlobalConfig.DbURL, config.GlobalConfig.DbLocation, config.SigningWindows())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db_utils.CloseDB(db)
+
+	migrator, ok := db.(db_utils.Migrator)
+	if !ok {
+		fmt.Println("the configured store has no schema to migrate")
+		return 0
+	}
+
+	if *apply {
+		if err := migrator.Migrate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	current, err := migrator.GetSchemaVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	statuses, err := migrator.GetMigrationStatus()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("schema version %d, latest supported %d\n", current, db_utils.LatestSchemaVersion())
+	pending := 0
+	for _, status := range statuses {
+		state := "applied " + status.AppliedAt
+		switch {
+		case status.Version > db_utils.LatestSchemaVersion():
+			state = "unknown, applied " + status.AppliedAt
+		case status.AppliedAt == "":
+			state = "pending"
+			pending++
+		}
+		fmt.Printf("  %3d  %-58s %s\n", status.Version, status.Description, state)
+	}
+
+	if current > db_utils.LatestSchemaVersion() {
+		fmt.Fprintln(os.Stderr, "the DB was migrated by a newer build, this build refuses to start against it")
+		return 1
+	}
+	if pending > 0 {
+		fmt.Printf("%d pending, they are applied on the next start or with --apply\n", pending)
+	}
+	return 0
+}
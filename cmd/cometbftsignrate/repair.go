@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cometbftsignrate/internal/chaindata"
+	"cometbftsignrate/internal/db_utils"
+)
+
+// runRepair finds the heights missing within the retained window of each chain and fetches them again
+func runRepair(args []string) int {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	configFile := flags.String("config", "./config.toml", "Path to the config file")
+	chainID := flags.String("chain", "", "Only repair this chain_id")
+	dryRun := flags.Bool("dry-run", false, "Only list the missing heights")
+	flags.Parse(args)
+
+	config, db, err := openConfigAndDB(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db_utils.CloseDB(db)
+
+	chains, err := selectChains(config, *chainID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	exitCode := 0
+	for _, chainConfig := range chains {
+		chain, err := chaindata.NewChain(chainConfig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+
+		gaps, err := chaindata.FindGaps(chain, db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", chain.ChainID, err)
+			exitCode = 1
+			continue
+		}
+		for _, validator := range chain.Validators {
+			missing := 0
+			for _, gap := range gaps[validator.Address] {
+				missing += gap.Count()
+			}
+			fmt.Printf("%s %s: %d missing heights\n", chain.ChainID, validator.Address, missing)
+			for _, gap := range gaps[validator.Address] {
+				fmt.Printf("  %d-%d\n", gap.From, gap.To)
+			}
+		}
+		if *dryRun {
+			continue
+		}
+
+		repaired, err := chaindata.RepairGaps(ctx, chain, db, gaps)
+		fmt.Printf("%s: repaired %d heights\n", chain.ChainID, repaired)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", chain.ChainID, err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
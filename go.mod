@@ -6,7 +6,10 @@ require github.com/mattn/go-sqlite3 v1.14.24
 
 require (
 	github.com/BurntSushi/toml v1.4.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/lib/pq v1.10.9
 	github.com/prometheus/client_golang v1.20.5
+	google.golang.org/protobuf v1.34.2
 )
 
 require (
@@ -18,5 +21,4 @@ require (
 	github.com/prometheus/common v0.55.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
 	golang.org/x/sys v0.22.0 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -1,9 +1,9 @@
 package api
 
 import (
+	"cometbftsignrate/internal/config_utils"
 	"cometbftsignrate/internal/db_utils"
 	"cometbftsignrate/internal/logger"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,7 +11,7 @@ import (
 	"time"
 )
 
-func APIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+func APIHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
 	// Get parameters from query
 	chainID := r.URL.Query().Get("chainID")
 	signingWindowStr := r.URL.Query().Get("signingWindow")
@@ -27,8 +27,16 @@ func APIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Resolve the validator by address or name, defaults to the first validator configured for the chain
+	validator, err := resolveValidator(chainID, r.URL.Query().Get("address"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	address := validator.Address
+
 	// Call the getAmountOfSignatureNotFound function
-	count, latestBlockTimestamp, err := db_utils.GetAmountOfSignatureNotFound(db, chainID, signingWindow)
+	count, latestBlockTimestamp, err := db.GetAmountOfSignatureNotFound(chainID, address, signingWindow)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -43,23 +51,88 @@ func APIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(latestBlockTime)
 	roundedDuration := int(duration.Seconds())
 
+	// Heights where the validator was not in the active set are not misses, they are left out of the rate
+	outOfActiveSet, err := db.GetOutOfActiveSetCount(chainID, address, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	inActiveSet, votingPower, err := db.GetLatestActiveSetStatus(chainID, address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Heights the RPC node could no longer serve are neither missed nor signed, they are left out of the rate as well
+	unreachable, err := db.GetUnreachableCount(chainID, address, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Calculate the signing rate percentage
-	signRate := float64(1) - (float64(count) / float64(signingWindow))
+	var signRate float64
+	if activeWindow := signingWindow - outOfActiveSet - unreachable; activeWindow > 0 {
+		signRate = float64(1) - (float64(count) / float64(activeWindow))
+	}
+
+	// Break the window down by block id flag
+	committed, nilVoted, absent, err := db.GetBlockIDFlagCounts(chainID, address, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Split the misses into ones where only we missed and ones the rest of the network missed as well
+	isolatedMisses, networkWideMisses, err := db.GetMissClassification(chainID, address, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Get number of signatures that failed verification
+	invalidSignatures, err := db.GetInvalidSignatureCount(chainID, address, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Get number of records in DB for this validator
+	numRecords, err := db.GetNumberOfRecordsForValidator(chainID, address)
+	if err != nil {
+		fmt.Printf("Error fetching number of records for chain %s address %s: %v\n", chainID, address, err)
+	}
 
-	// Get number of records in DB for this chain
-	numRecords, err := db_utils.GetNumberOfRecordsForChain(db, chainID)
+	// Forecast the jail risk from the x/slashing params, only when the chain has a REST endpoint
+	chain, _ := config_utils.GetChain(chainID)
+	forecast, hasForecast, err := jailForecast(db, chain, address)
 	if err != nil {
-		fmt.Printf("Error fetching number of records for chain %s: %v\n", chainID, err)
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "jailForecast", Success: false, Message: err.Error()})
 	}
 
 	response := map[string]interface{}{
 		"chainID":                          chainID,
+		"address":                          address,
+		"name":                             validator.Name,
 		"requestedSigningWindow":           signingWindow,
 		"missedSignatureCount":             count,
 		"signingRatePercentage":            signRate,
 		"latestBlockTimestamp":             latestBlockTimestamp,
 		"secondsSinceLatestBlockTimestamp": roundedDuration,
 		"availableRecords":                 numRecords,
+		"committedCount":                   committed,
+		"nilVoteCount":                     nilVoted,
+		"absentCount":                      absent,
+		"invalidSignatureCount":            invalidSignatures,
+		"isolatedMissCount":                isolatedMisses,
+		"networkWideMissCount":             networkWideMisses,
+		"outOfActiveSetCount":              outOfActiveSet,
+		"unreachableCount":                 unreachable,
+		"inActiveSet":                      inActiveSet,
+		"votingPower":                      votingPower,
+	}
+	if hasForecast {
+		response["slashing"] = forecast
 	}
 
 	// Set response headers and encode response as JSON
@@ -70,3 +143,24 @@ func APIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "API HTTP Request", Success: true})
 }
 
+// resolveValidator finds the tracked validator of chainID matching addressOrName, an empty value selects the chains first validator
+func resolveValidator(chainID string, addressOrName string) (config_utils.ValidatorConfig, error) {
+	chain, ok := config_utils.GetChain(chainID)
+	if !ok {
+		return config_utils.ValidatorConfig{}, fmt.Errorf("chain_id %s not found", chainID)
+	}
+
+	if addressOrName == "" {
+		validators := chain.Validators()
+		if len(validators) == 0 {
+			return config_utils.ValidatorConfig{}, fmt.Errorf("no validator configured for chain_id %s", chainID)
+		}
+		return validators[0], nil
+	}
+
+	validator, ok := chain.FindValidator(addressOrName)
+	if !ok {
+		return config_utils.ValidatorConfig{}, fmt.Errorf("validator %s is not tracked on chain_id %s", addressOrName, chainID)
+	}
+	return validator, nil
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+type ErrorKind int
+
+const (
+	// ErrTransient covers network errors, timeouts and 5xx responses - worth retrying
+	ErrTransient ErrorKind = iota
+	// ErrHeightNotAvailable means the node has pruned the height or has not reached it yet
+	ErrHeightNotAvailable
+	// ErrChainIDMismatch means the node serves a different network than configured
+	ErrChainIDMismatch
+	// ErrMalformedResponse means the node answered with something that could not be decoded
+	ErrMalformedResponse
+	// ErrConfig means the chain config itself is invalid, retrying will not help
+	ErrConfig
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrTransient:
+		return "transient"
+	case ErrHeightNotAvailable:
+		return "height-not-available"
+	case ErrChainIDMismatch:
+		return "chain-id-mismatch"
+	case ErrMalformedResponse:
+		return "malformed-response"
+	case ErrConfig:
+		return "config"
+	}
+	return "unknown"
+}
+
+// RPCError is returned by every RPC client function so callers can decide whether to retry, fail over or stop
+type RPCError struct {
+	Kind   ErrorKind
+	Op     string
+	Host   string
+	Height int
+	Err    error
+}
+
+func (e *RPCError) Error() string {
+	msg := fmt.Sprintf("%s: %s error", e.Op, e.Kind)
+	if e.Host != "" {
+		msg += fmt.Sprintf(" from %s", e.Host)
+	}
+	if e.Height != 0 {
+		msg += fmt.Sprintf(" at height %d", e.Height)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf returns the kind of an RPCError, errors that were not classified are treated as transient
+func ErrorKindOf(err error) ErrorKind {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Kind
+	}
+	return ErrTransient
+}
+
+// IsFatal reports whether err means the chains worker can not continue without a config change
+func IsFatal(err error) bool {
+	if err == nil {
+		return false
+	}
+	kind := ErrorKindOf(err)
+	return kind == ErrConfig || kind == ErrChainIDMismatch
+}
+
+// classifyRPCError maps the error message of a CometBFT JSON-RPC error response to an ErrorKind
+func classifyRPCError(message string) ErrorKind {
+	switch {
+	case strings.Contains(message, "is not available, lowest height is"),
+		strings.Contains(message, "must be less than or equal to the current blockchain height"),
+		strings.Contains(message, "could not find results for height"):
+		return ErrHeightNotAvailable
+	}
+	return ErrTransient
+}
+
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+var DefaultBackoff = Backoff{
+	Initial:  500 * time.Millisecond,
+	Max:      30 * time.Second,
+	Attempts: 5,
+}
+
+// delay returns the exponential backoff for attempt (starting at 0) with jitter between 50% and 100% of it
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Initial << attempt
+	if d > b.Max || d <= 0 {
+		d = b.Max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Retry calls request until it succeeds, returns a non transient error, runs out of attempts or ctx is cancelled
+func Retry(ctx context.Context, backoff Backoff, request func() error) error {
+	var err error
+	for attempt := 0; attempt < backoff.Attempts; attempt++ {
+		err = request()
+		if err == nil || ErrorKindOf(err) != ErrTransient {
+			return err
+		}
+		if attempt == backoff.Attempts-1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff.delay(attempt)):
+		}
+	}
+	return err
+}
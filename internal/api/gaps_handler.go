@@ -0,0 +1,73 @@
+package api
+
+import (
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type KnownGapResponse struct {
+	FromHeight int    `json:"fromHeight"`
+	ToHeight   int    `json:"toHeight"`
+	Count      int    `json:"count"`
+	Reason     string `json:"reason"`
+	RecordedAt string `json:"recordedAt"`
+}
+
+// GapsHandler lists the height ranges of a chain that could not be fetched, they are neither missed nor signed
+func GapsHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
+	// Get parameters from query
+	chainID := r.URL.Query().Get("chainID")
+	if chainID == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+	if _, ok := config_utils.GetChain(chainID); !ok {
+		http.Error(w, fmt.Sprintf("chain_id %s not found", chainID), http.StatusNotFound)
+		return
+	}
+
+	// fromHeight and toHeight are optional bounds
+	var fromHeight, toHeight int
+	var err error
+	if value := r.URL.Query().Get("fromHeight"); value != "" {
+		if fromHeight, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid fromHeight", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("toHeight"); value != "" {
+		if toHeight, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid toHeight", http.StatusBadRequest)
+			return
+		}
+	}
+
+	gaps, err := db.GetKnownGaps(chainID, fromHeight, toHeight)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]KnownGapResponse, 0, len(gaps))
+	for _, gap := range gaps {
+		response = append(response, KnownGapResponse{
+			FromHeight: gap.FromHeight,
+			ToHeight:   gap.ToHeight,
+			Count:      gap.ToHeight - gap.FromHeight + 1,
+			Reason:     gap.Reason,
+			RecordedAt: gap.RecordedAt,
+		})
+	}
+
+	// Set response headers and encode response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "Gaps HTTP Request", Success: true})
+}
@@ -2,17 +2,20 @@ package api
 
 import (
 	"cometbftsignrate/internal/logger"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 )
 
 type SyncInfo struct {
-	LatestBlockHeight string `json:"latest_block_height"`
+	LatestBlockHeight   string `json:"latest_block_height"`
+	LatestBlockTime     string `json:"latest_block_time"`
+	EarliestBlockHeight string `json:"earliest_block_height"`
+	CatchingUp          bool   `json:"catching_up"`
 }
 
 type CurrentHeightResponse struct {
@@ -24,109 +27,270 @@ type CurrentHeightResponse struct {
 	} `json:"result"`
 }
 
+type BlockID struct {
+	Hash  string `json:"hash"`
+	Parts struct {
+		Total int    `json:"total"`
+		Hash  string `json:"hash"`
+	} `json:"parts"`
+}
+
+type Block struct {
+	Data struct {
+		Txs []string `json:"txs"`
+	} `json:"data"`
+	Header struct {
+		Height          string `json:"height"`
+		Time            string `json:"time"`
+		ProposerAddress string `json:"proposer_address"`
+		ValidatorsHash  string `json:"validators_hash"`
+	} `json:"header"`
+	LastCommit struct {
+		Height     string      `json:"height"`
+		Round      int         `json:"round"`
+		BlockID    BlockID     `json:"block_id"`
+		Signatures []CommitSig `json:"signatures"`
+	} `json:"last_commit"`
+}
+
+// CommitSig is one slot of a commit, slots are in the order of the validator set of the commit height
+type CommitSig struct {
+	BlockIDFlag      int    `json:"block_id_flag"`
+	ValidatorAddress string `json:"validator_address"`
+	Timestamp        string `json:"timestamp"`
+	Signature        string `json:"signature"`
+}
+
+// Flag returns the block id flag of the slot, nodes that predate the flag only tell us whether there is a signature
+func (s CommitSig) Flag() int {
+	if s.BlockIDFlag == BlockIDFlagUnknown {
+		if s.Signature != "" {
+			return BlockIDFlagCommit
+		}
+		return BlockIDFlagAbsent
+	}
+	return s.BlockIDFlag
+}
+
 type BlockResult struct {
 	Result struct {
-		Block struct {
-			Data struct {
-				Txs []string `json:"txs"`
-			} `json:"data"`
-			Header struct {
-				Time            string `json:"time"`
-				ProposerAddress string `json:"proposer_address"`
-			} `json:"header"`
-			LastCommit struct {
-				Signatures []struct {
-					ValidatorAddress string `json:"validator_address"`
-					Timestamp        string `json:"timestamp"`
-					Signature        string `json:"signature"`
-				} `json:"signatures"`
-			} `json:"last_commit"`
-		} `json:"block"`
+		Block Block `json:"block"`
 	} `json:"result"`
 }
 
-func GetCurrentHeight(chainID string, address string) (int, error) {
-	url := fmt.Sprintf("%s/status", address)
+type rpcErrorResponse struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+}
 
-	resp, err := http.Get(url)
+var rpcClient = &http.Client{Timeout: 10 * time.Second}
+
+// rpcGet queries path on host and decodes the JSON response into out, every error is returned as an *RPCError
+func rpcGet(operation string, host string, path string, height int, out interface{}) error {
+	return rpcGetWithHeader(operation, host, path, height, nil, out)
+}
+
+// rpcGetWithHeader is rpcGet with extra request headers
+func rpcGetWithHeader(operation string, host string, path string, height int, header http.Header, out interface{}) error {
+	url := fmt.Sprintf("%s%s", host, path)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getCurrentHeight", Success: false, Message: err.Error()})
-		os.Exit(1)
+		return &RPCError{Kind: ErrConfig, Op: operation, Host: host, Height: height, Err: err}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := rpcClient.Do(req)
+	if err != nil {
+		return &RPCError{Kind: ErrTransient, Op: operation, Host: host, Height: height, Err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getCurrentHeight", Success: false, Message: err.Error()})
-		os.Exit(1)
+		return &RPCError{Kind: ErrTransient, Op: operation, Host: host, Height: height, Err: err}
 	}
 
+	// CometBFT returns JSON-RPC errors (e.g. pruned heights) with a 500 status code
+	var errResponse rpcErrorResponse
+	if json.Unmarshal(body, &errResponse) == nil && errResponse.Error != nil {
+		message := errResponse.Error.Message + ": " + errResponse.Error.Data
+		return &RPCError{Kind: classifyRPCError(message), Op: operation, Host: host, Height: height, Err: fmt.Errorf("%s", message)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &RPCError{Kind: ErrTransient, Op: operation, Host: host, Height: height, Err: fmt.Errorf("unexpected status code %d", resp.StatusCode)}
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return &RPCError{Kind: ErrMalformedResponse, Op: operation, Host: host, Height: height, Err: err}
+	}
+	return nil
+}
+
+// GetNodeStatus queries /status of a single node (also checks if chainID matches the nodes chainID)
+func GetNodeStatus(chainID string, host string) (SyncInfo, error) {
 	var currentHeightResponse CurrentHeightResponse
-	err = json.Unmarshal(body, &currentHeightResponse)
-	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getCurrentHeight", Success: false, Message: err.Error()})
-		os.Exit(1)
+	if err := rpcGet("getCurrentHeight", host, "/status", 0, &currentHeightResponse); err != nil {
+		return SyncInfo{}, err
 	}
 
 	// check chainID matches nodes chainID
 	nodeChainID := currentHeightResponse.Result.NodeInfo.Network
 	if nodeChainID != chainID {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getCurrentHeight", Success: false, Message: fmt.Sprintf("Chain ID mismatch: %s != %s", chainID, nodeChainID)})
-		os.Exit(1)
+		return SyncInfo{}, &RPCError{Kind: ErrChainIDMismatch, Op: "getCurrentHeight", Host: host, Err: fmt.Errorf("Chain ID mismatch: %s != %s", chainID, nodeChainID)}
+	}
+
+	return currentHeightResponse.Result.SyncInfo, nil
+}
+
+// GetCurrentHeight returns the latest block height from the healthiest host in the pool
+func GetCurrentHeight(chainID string, pool *HostPool) (int, error) {
+	status, err := GetChainStatus(chainID, pool)
+	if err != nil {
+		return 0, err
 	}
+	return status.LatestHeight, nil
+}
+
+// ChainStatus is the sync state of the RPC node that answered /status
+type ChainStatus struct {
+	Host         string
+	LatestHeight int
+	// lowest height the node still serves, 0 if the node did not report it
+	EarliestHeight  int
+	LatestBlockTime time.Time
+	CatchingUp      bool
+}
+
+// GetChainStatus queries /status on the healthiest node of pool
+func GetChainStatus(chainID string, pool *HostPool) (ChainStatus, error) {
+	var status ChainStatus
+	err := pool.Do("getCurrentHeight", func(host string) error {
+		syncInfo, err := GetNodeStatus(chainID, host)
+		if err != nil {
+			return err
+		}
+		pool.ReportCatchingUp(host, syncInfo.CatchingUp)
 
-	// convert string to int
-	str := currentHeightResponse.Result.SyncInfo.LatestBlockHeight
-	num, err := strconv.Atoi(str)
+		// convert string to int
+		latestHeight, err := strconv.Atoi(syncInfo.LatestBlockHeight)
+		if err != nil {
+			return &RPCError{Kind: ErrMalformedResponse, Op: "getCurrentHeight", Host: host, Err: err}
+		}
+		status = ChainStatus{Host: host, LatestHeight: latestHeight, CatchingUp: syncInfo.CatchingUp}
+
+		// older nodes do not report these, they are left at their zero value
+		if earliestHeight, err := strconv.Atoi(syncInfo.EarliestBlockHeight); err == nil {
+			status.EarliestHeight = earliestHeight
+		}
+		if latestBlockTime, err := time.Parse(time.RFC3339Nano, syncInfo.LatestBlockTime); err == nil {
+			status.LatestBlockTime = latestBlockTime
+		}
+		return nil
+	})
 	if err != nil {
 		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getCurrentHeight", Success: false, Message: err.Error()})
-		os.Exit(1)
+		return ChainStatus{}, err
 	}
 
-	return num, nil
+	return status, nil
 }
 
-func CheckBlockSignature(ChainID string, host string, address string, height int, delay string) (string, bool, string, string, bool, int, bool) {
-	if delay != "0ms" {
+// CometBFT BlockIDFlag of a commit signature
+const (
+	BlockIDFlagUnknown = 0
+	BlockIDFlagAbsent  = 1
+	BlockIDFlagCommit  = 2
+	BlockIDFlagNil     = 3
+)
+
+// BlockSignature is the signature and proposer data of a single block for one validator address.
+// The signature data comes from the blocks last_commit and belongs to CommitHeight (Height-1),
+// the proposer and transaction data belongs to Height.
+type BlockSignature struct {
+	Height             int
+	CommitHeight       int
+	Timestamp          string
+	SignatureFound     bool
+	BlockIDFlag        int
+	ValidatorTimestamp string
+	Signature          string
+	ProposerMatch      bool
+	NumTXs             int
+	EmptyBlock         bool
+	// round and block id of the commit, needed to rebuild the precommit sign bytes
+	CommitRound        int
+	CommitBlockID      BlockID
+	VerificationStatus int
+}
+
+// FetchBlock fetches the block at height once, its signatures are extracted per validator with ParseBlockSignature.
+// The rpc_delay before the request ends early if ctx is cancelled.
+func FetchBlock(ctx context.Context, ChainID string, pool *HostPool, height int, delay string) (Block, error) {
+	if delay != "" && delay != "0ms" {
 		delayDuration, err := time.ParseDuration(delay)
 		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: ChainID, Operation: "checkBlockSignature", Success: false, Message: err.Error()})
-			os.Exit(1)
+			return Block{}, &RPCError{Kind: ErrConfig, Op: "fetchBlock", Height: height, Err: fmt.Errorf("invalid rpc_delay %q: %v", delay, err)}
+		}
+		// a cancelled range does not wait out the delay of every fetch in flight
+		select {
+		case <-ctx.Done():
+			return Block{}, ctx.Err()
+		case <-time.After(delayDuration):
 		}
-		time.Sleep(delayDuration)
 	}
-	url := fmt.Sprintf("%s/block?height=%d", host, height)
 
-	resp, err := http.Get(url)
+	var block Block
+	err := pool.Do("fetchBlock", func(host string) error {
+		var blockData BlockResult
+		if err := rpcGet("fetchBlock", host, fmt.Sprintf("/block?height=%d", height), height, &blockData); err != nil {
+			return err
+		}
+		block = blockData.Result.Block
+		return nil
+	})
 	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: ChainID, Operation: "checkBlockSignature", Success: false, Message: err.Error()})
-		os.Exit(1)
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: ChainID, Operation: "fetchBlock", Height: height, Success: false, Message: err.Error()})
+		return Block{}, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: ChainID, Operation: "checkBlockSignature", Success: false, Message: err.Error()})
-		os.Exit(1)
-	}
+	return block, nil
+}
 
-	var blockData BlockResult
-	err = json.Unmarshal(body, &blockData)
-	if err != nil {
-		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: ChainID, Operation: "checkBlockSignature", Success: false, Message: err.Error()})
-		os.Exit(1)
+// CommitHeight returns the height the last_commit of block at height refers to
+func CommitHeight(block Block, height int) int {
+	// last_commit holds the precommits for the previous height
+	commitHeight, err := strconv.Atoi(block.LastCommit.Height)
+	if err != nil || commitHeight == 0 {
+		return height - 1
 	}
+	return commitHeight
+}
 
+// ParseBlockSignature extracts the signature and proposer data for address from an already fetched block
+func ParseBlockSignature(ChainID string, block Block, address string, height int) BlockSignature {
 	// Set block timestamp
-	time := blockData.Result.Block.Header.Time
+	time := block.Header.Time
+
+	commitHeight := CommitHeight(block, height)
 
 	// Check if signature is found
+	// absent slots carry an empty address, so a validator without a matching entry did not vote
 	var signatureFound bool
 	var signature string
 	var valTimestamp string
-	for _, sig := range blockData.Result.Block.LastCommit.Signatures {
+	blockIDFlag := BlockIDFlagAbsent
+	for _, sig := range block.LastCommit.Signatures {
 		if sig.ValidatorAddress == address {
-			signatureFound = true
+			blockIDFlag = sig.Flag()
+			// a nil precommit is signed but does not commit the block
+			signatureFound = blockIDFlag == BlockIDFlagCommit
 			valTimestamp = sig.Timestamp
 			signature = sig.Signature
 			break
@@ -135,13 +299,13 @@ func CheckBlockSignature(ChainID string, host string, address string, height int
 
 	// Check block proposer address
 	var proposerMatch bool
-	proposerAddress := blockData.Result.Block.Header.ProposerAddress
+	proposerAddress := block.Header.ProposerAddress
 	if address == proposerAddress {
 		proposerMatch = true
 	}
 
 	// Check number of TXs in block
-	numTXs := len(blockData.Result.Block.Data.Txs)
+	numTXs := len(block.Data.Txs)
 
 	var emptyBlock bool
 	if numTXs == 0 {
@@ -149,5 +313,87 @@ func CheckBlockSignature(ChainID string, host string, address string, height int
 	}
 
 	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: ChainID, Operation: "checkBlockSignature", Height: height, SignatureFound: signatureFound})
-	return time, signatureFound, valTimestamp, signature, proposerMatch, numTXs, emptyBlock
+	return BlockSignature{
+		Height:             height,
+		CommitHeight:       commitHeight,
+		Timestamp:          time,
+		SignatureFound:     signatureFound,
+		BlockIDFlag:        blockIDFlag,
+		ValidatorTimestamp: valTimestamp,
+		Signature:          signature,
+		ProposerMatch:      proposerMatch,
+		NumTXs:             numTXs,
+		EmptyBlock:         emptyBlock,
+		CommitRound:        block.LastCommit.Round,
+		CommitBlockID:      block.LastCommit.BlockID,
+	}
+}
+
+type Validator struct {
+	Address string `json:"address"`
+	PubKey  struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"pub_key"`
+	VotingPower      string `json:"voting_power"`
+	ProposerPriority string `json:"proposer_priority"`
+}
+
+// FindValidator returns the validator with address from validators
+func FindValidator(validators []Validator, address string) (Validator, bool) {
+	for _, validator := range validators {
+		if validator.Address == address {
+			return validator, true
+		}
+	}
+	return Validator{}, false
+}
+
+// Power returns the voting power of the validator, 0 if it can not be parsed
+func (v Validator) Power() int64 {
+	power, err := strconv.ParseInt(v.VotingPower, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return power
+}
+
+type ValidatorsResult struct {
+	Result struct {
+		BlockHeight string      `json:"block_height"`
+		Validators  []Validator `json:"validators"`
+		Total       string      `json:"total"`
+	} `json:"result"`
+}
+
+// maximum page size allowed by CometBFT's /validators
+const validatorsPerPage = 100
+
+// GetValidators returns the full validator set at height, following /validators pagination
+func GetValidators(chainID string, pool *HostPool, height int) ([]Validator, error) {
+	var validators []Validator
+	err := pool.Do("getValidators", func(host string) error {
+		validators = nil
+		for page := 1; ; page++ {
+			var result ValidatorsResult
+			path := fmt.Sprintf("/validators?height=%d&page=%d&per_page=%d", height, page, validatorsPerPage)
+			if err := rpcGet("getValidators", host, path, height, &result); err != nil {
+				return err
+			}
+			validators = append(validators, result.Result.Validators...)
+
+			total, err := strconv.Atoi(result.Result.Total)
+			if err != nil {
+				return &RPCError{Kind: ErrMalformedResponse, Op: "getValidators", Host: host, Height: height, Err: err}
+			}
+			if len(validators) >= total || len(result.Result.Validators) == 0 {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getValidators", Height: height, Success: false, Message: err.Error()})
+		return nil, err
+	}
+	return validators, nil
 }
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type IncidentResponse struct {
+	ChainID         string `json:"chainID"`
+	Address         string `json:"address"`
+	Name            string `json:"name,omitempty"`
+	StartHeight     int    `json:"startHeight"`
+	EndHeight       int    `json:"endHeight"`
+	StartTime       string `json:"startTime"`
+	EndTime         string `json:"endTime"`
+	DurationSeconds int    `json:"durationSeconds"`
+	BlockCount      int    `json:"blockCount"`
+	Status          string `json:"status"`
+	ResolvedHeight  int    `json:"resolvedHeight,omitempty"`
+}
+
+// IncidentsHandler lists the streaks of consecutive misses of a chain, newest first
+func IncidentsHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
+	// Get parameters from query
+	chainID := r.URL.Query().Get("chainID")
+	if chainID == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+
+	chain, ok := config_utils.GetChain(chainID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("chain_id %s not found", chainID), http.StatusNotFound)
+		return
+	}
+
+	// address is optional, without it the incidents of every tracked validator are returned
+	var address string
+	if value := r.URL.Query().Get("address"); value != "" {
+		validator, ok := chain.FindValidator(value)
+		if !ok {
+			http.Error(w, fmt.Sprintf("validator %s is not tracked on chain_id %s", value, chainID), http.StatusNotFound)
+			return
+		}
+		address = validator.Address
+	}
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from, expected an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to, expected an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != db_utils.IncidentOpen && status != db_utils.IncidentResolved {
+		http.Error(w, "Invalid status, expected open or resolved", http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := db.GetIncidents(chainID, address, from, to, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]IncidentResponse, 0, len(incidents))
+	for _, incident := range incidents {
+		tracked, _ := chain.FindValidator(incident.Address)
+		response = append(response, IncidentResponse{
+			ChainID:         incident.ChainID,
+			Address:         incident.Address,
+			Name:            tracked.Name,
+			StartHeight:     incident.StartHeight,
+			EndHeight:       incident.EndHeight,
+			StartTime:       incident.StartTime,
+			EndTime:         incident.EndTime,
+			DurationSeconds: incidentDuration(incident),
+			BlockCount:      incident.BlockCount,
+			Status:          incident.Status,
+			ResolvedHeight:  incident.ResolvedHeight,
+		})
+	}
+
+	// Set response headers and encode response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "Incidents HTTP Request", Success: true})
+}
+
+// parseTimeParam normalises an optional RFC3339 timestamp to UTC so it compares with the stored block timestamps
+func parseTimeParam(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// incidentDuration returns the seconds between the first and the last missed block of the incident
+func incidentDuration(incident db_utils.Incident) int {
+	start, err := time.Parse(time.RFC3339, incident.StartTime)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse(time.RFC3339, incident.EndTime)
+	if err != nil {
+		return 0
+	}
+	return int(end.Sub(start).Seconds())
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type LeaderboardValidator struct {
+	Rank                  int     `json:"rank"`
+	Address               string  `json:"address"`
+	Name                  string  `json:"name,omitempty"`
+	Tracked               bool    `json:"tracked"`
+	Blocks                int     `json:"blocks"`
+	CommittedCount        int     `json:"committedCount"`
+	NilVoteCount          int     `json:"nilVoteCount"`
+	AbsentCount           int     `json:"absentCount"`
+	MissedSignatureCount  int     `json:"missedSignatureCount"`
+	ProposedBlocks        int     `json:"proposedBlocks"`
+	SigningRatePercentage float64 `json:"signingRatePercentage"`
+}
+
+// LeaderboardHandler ranks every validator of a chain with track_validator_set by signing rate over the requested window
+func LeaderboardHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
+	// Get parameters from query
+	chainID := r.URL.Query().Get("chainID")
+	signingWindowStr := r.URL.Query().Get("signingWindow")
+
+	if chainID == "" || signingWindowStr == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+
+	signingWindow, err := strconv.Atoi(signingWindowStr)
+	if err != nil {
+		http.Error(w, "Invalid number of records", http.StatusBadRequest)
+		return
+	}
+
+	chain, ok := config_utils.GetChain(chainID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("chain_id %s not found", chainID), http.StatusNotFound)
+		return
+	}
+	if !chain.TrackValidatorSet {
+		http.Error(w, fmt.Sprintf("track_validator_set is not enabled for chain_id %s", chainID), http.StatusNotFound)
+		return
+	}
+
+	entries, err := db.GetLeaderboard(chainID, signingWindow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	validators := make([]LeaderboardValidator, 0, len(entries))
+	for i, entry := range entries {
+		tracked, isTracked := chain.FindValidator(entry.Address)
+		validators = append(validators, LeaderboardValidator{
+			Rank:                  i + 1,
+			Address:               entry.Address,
+			Name:                  tracked.Name,
+			Tracked:               isTracked,
+			Blocks:                entry.Blocks,
+			CommittedCount:        entry.Committed,
+			NilVoteCount:          entry.NilVoted,
+			AbsentCount:           entry.Absent,
+			MissedSignatureCount:  entry.Blocks - entry.Committed,
+			ProposedBlocks:        entry.Proposed,
+			SigningRatePercentage: entry.SigningRate,
+		})
+	}
+
+	response := map[string]interface{}{
+		"chainID":                chainID,
+		"requestedSigningWindow": signingWindow,
+		"validators":             validators,
+	}
+
+	// Set response headers and encode response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "Leaderboard HTTP Request", Success: true})
+}
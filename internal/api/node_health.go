@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// NodeHealth is the sync state of the RPC node a chain is ingested from, as of the last check
+type NodeHealth struct {
+	ChainID         string  `json:"chainID"`
+	Host            string  `json:"host"`
+	Healthy         bool    `json:"healthy"`
+	CatchingUp      bool    `json:"catchingUp"`
+	LatestHeight    int     `json:"latestHeight"`
+	LatestBlockTime string  `json:"latestBlockTime,omitempty"`
+	LagSeconds      float64 `json:"lagSeconds"`
+	StalledSeconds  float64 `json:"stalledSeconds"`
+	// why ingestion is paused, empty while the node is healthy
+	Reason    string `json:"reason,omitempty"`
+	CheckedAt string `json:"checkedAt"`
+}
+
+var (
+	nodeHealthMu sync.Mutex
+	nodeHealth   = make(map[string]NodeHealth)
+)
+
+// SetNodeHealth records the latest health check of a chains node and updates its metrics
+func SetNodeHealth(health NodeHealth) {
+	nodeHealthMu.Lock()
+	nodeHealth[health.ChainID] = health
+	nodeHealthMu.Unlock()
+
+	healthy, catchingUp := 0.0, 0.0
+	if health.Healthy {
+		healthy = 1
+	}
+	if health.CatchingUp {
+		catchingUp = 1
+	}
+	NodeHealthy.WithLabelValues(health.ChainID).Set(healthy)
+	NodeCatchingUp.WithLabelValues(health.ChainID).Set(catchingUp)
+	NodeLagSeconds.WithLabelValues(health.ChainID).Set(health.LagSeconds)
+	NodeHeightStalledSeconds.WithLabelValues(health.ChainID).Set(health.StalledSeconds)
+}
+
+// NodeHealthHandler lists the node health of every chain, or only of chainID if given
+func NodeHealthHandler(w http.ResponseWriter, r *http.Request) {
+	chainID := r.URL.Query().Get("chainID")
+
+	nodeHealthMu.Lock()
+	response := []NodeHealth{}
+	for _, health := range nodeHealth {
+		if chainID == "" || health.ChainID == chainID {
+			response = append(response, health)
+		}
+	}
+	nodeHealthMu.Unlock()
+	sort.Slice(response, func(i, j int) bool { return response[i].ChainID < response[j].ChainID })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
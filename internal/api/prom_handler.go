@@ -4,8 +4,9 @@ import (
 	"cometbftsignrate/internal/config_utils"
 	"cometbftsignrate/internal/db_utils"
 	"cometbftsignrate/internal/logger"
-	"database/sql"
+	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
@@ -20,7 +21,7 @@ var (
 			Name: "signature_not_found_count",
 			Help: "Number of signature not found events.",
 		},
-		[]string{"chainID", "address"},
+		[]string{"chainID", "address", "name"},
 	)
 
 	SigningRatePercentage = prometheus.NewGaugeVec(
@@ -28,7 +29,7 @@ var (
 			Name: "signing_rate_percentage",
 			Help: "Percentage of successful signing.",
 		},
-		[]string{"chainID", "address"},
+		[]string{"chainID", "address", "name"},
 	)
 
 	SecondsSinceLatestBlockTimestamp = prometheus.NewGaugeVec(
@@ -50,28 +51,240 @@ var (
 			Name: "signing_window_size",
 			Help: "Signing window size defined in config.toml or if not enough data is available, the value is the number of records available in DB.",
 		},
-		[]string{"chainID"},
+		[]string{"chainID", "address", "name"},
 	)
 	NumberOfProposedBlocks = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "number_of_proposed_blocks",
 			Help: "Number of proposed blocks in signing window.",
 		},
-		[]string{"chainID", "address", "signing_window"},
+		[]string{"chainID", "address", "name", "signing_window"},
 	)
 	NumberOfEmptyProposedBlocks = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "number_of_empty_proposed_blocks",
 			Help: "Number of proposed blocks with zero TXs in them during the signing window.",
 		},
-		[]string{"chainID", "address", "signing_window"},
+		[]string{"chainID", "address", "name", "signing_window"},
 	)
 	LastBlockTimeDiff = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "proposer_signature_time_diff_ms",
 			Help: "Average difference between proposer and validator timestamps in milliseconds for last 25 blocks.",
 		},
-		[]string{"chainID", "address"},
+		[]string{"chainID", "address", "name"},
+	)
+	BlockIDFlagCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "block_id_flag_count",
+			Help: "Number of committed, nil-voted and absent signatures in the signing window.",
+		},
+		[]string{"chainID", "address", "name", "flag"},
+	)
+	InvalidSignatureCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "invalid_signature_count",
+			Help: "Number of precommit signatures in the signing window that failed ed25519 verification.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	CurrentConsecutiveMisses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "current_consecutive_misses",
+			Help: "Number of consecutive blocks the validator has missed up to the latest stored height, 0 if it signed the latest one.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	SlashingSignedBlocksWindow = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slashing_signed_blocks_window",
+			Help: "The x/slashing signed_blocks_window param of the chain.",
+		},
+		[]string{"chainID"},
+	)
+	SlashingMinSignedPerWindow = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slashing_min_signed_per_window",
+			Help: "The x/slashing min_signed_per_window param of the chain.",
+		},
+		[]string{"chainID"},
+	)
+	RemainingMissBudget = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "remaining_miss_budget",
+			Help: "Number of blocks the validator can still miss within the slashing window before it is jailed.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	BlocksUntilJail = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blocks_until_jail",
+			Help: "Projected number of blocks until the validator is jailed at its current miss rate, +Inf if it is not on track to be jailed.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	SecondsUntilJail = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "seconds_until_jail",
+			Help: "Projected number of seconds until the validator is jailed at its current miss rate and the average block time, +Inf if it is not on track to be jailed.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	SigningInfoMissedBlocks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "signing_info_missed_blocks",
+			Help: "The missed_blocks_counter of the validators x/slashing signing info at the latest stored height.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	SigningInfoDivergence = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "signing_info_divergence",
+			Help: "The chains missed_blocks_counter minus the misses counted from the DB over the same window, non-zero points at DB gaps or ingestion bugs.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	UnreachableHeights = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "unreachable_heights",
+			Help: "Number of heights in the signing window recorded as a known gap because the RPC nodes had pruned them.",
+		},
+		[]string{"chainID"},
+	)
+	MissingHeights = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "missing_heights",
+			Help: "Number of heights within the retained window that have no record for the validator after the last gap scan.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	IsolatedMissCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "isolated_miss_count",
+			Help: "Number of misses in the signing window while the rest of the network committed the block.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	NetworkWideMissCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "network_wide_miss_count",
+			Help: "Number of misses in the signing window where more than network_miss_threshold of the rest of the network missed as well.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	OutOfActiveSetCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "out_of_active_set_count",
+			Help: "Number of heights in the signing window where the validator was not in the active set, they are not counted as missed.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	InActiveSet = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "in_active_set",
+			Help: "1 if the validator was in the active set at the latest stored height, 0 otherwise.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	ValidatorVotingPower = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "validator_voting_power",
+			Help: "Voting power of the validator at the latest stored height.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	SignatureVerificationFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "signature_verification_failures_total",
+			Help: "Number of precommit signatures that failed ed25519 verification since start.",
+		},
+		[]string{"chainID", "address", "name"},
+	)
+	RPCEndpointActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rpc_endpoint_active",
+			Help: "1 if the RPC endpoint is currently serving requests for the chain, 0 otherwise.",
+		},
+		[]string{"chainID", "host"},
+	)
+	RPCEndpointHealthScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rpc_endpoint_health_score",
+			Help: "Health score (0-100) of the RPC endpoint based on latency, errors and catching_up.",
+		},
+		[]string{"chainID", "host"},
+	)
+	RPCEndpointLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rpc_endpoint_latency_ms",
+			Help: "Moving average of the RPC endpoint response time in milliseconds.",
+		},
+		[]string{"chainID", "host"},
+	)
+	RPCEndpointErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rpc_endpoint_errors_total",
+			Help: "Number of failed requests to the RPC endpoint.",
+		},
+		[]string{"chainID", "host"},
+	)
+	NodeHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_healthy",
+			Help: "1 if the RPC node of the chain is synced and advancing, 0 while ingestion is paused because it is not.",
+		},
+		[]string{"chainID"},
+	)
+	NodeCatchingUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_catching_up",
+			Help: "1 if the RPC node of the chain reports catching_up in /status.",
+		},
+		[]string{"chainID"},
+	)
+	NodeLagSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_lag_seconds",
+			Help: "Seconds between the wall clock and the latest block time reported by the RPC node.",
+		},
+		[]string{"chainID"},
+	)
+	NodeHeightStalledSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_height_stalled_seconds",
+			Help: "Seconds since the latest height reported by the RPC node last advanced.",
+		},
+		[]string{"chainID"},
+	)
+	BatchHeights = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_batch_heights",
+			Help:    "Number of heights written per committed transaction.",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		},
+		[]string{"chainID"},
+	)
+	BatchCommitLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_batch_commit_seconds",
+			Help:    "Time taken to commit a transaction of heights.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"chainID"},
+	)
+	WorkerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_state",
+			Help: "1 for the current state (running, degraded, stopped, crashed) of each chain worker, 0 for the others.",
+		},
+		[]string{"chainID", "worker", "state"},
+	)
+	WorkerRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "worker_restarts_total",
+			Help: "Number of times the supervisor restarted a chain worker.",
+		},
+		[]string{"chainID", "worker"},
 	)
 )
 
@@ -88,6 +301,36 @@ func InitMetrics() (*prometheus.Registry, error) {
 	customRegistry.MustRegister(NumberOfProposedBlocks)
 	customRegistry.MustRegister(NumberOfEmptyProposedBlocks)
 	customRegistry.MustRegister(LastBlockTimeDiff)
+	customRegistry.MustRegister(BlockIDFlagCount)
+	customRegistry.MustRegister(InvalidSignatureCount)
+	customRegistry.MustRegister(SignatureVerificationFailures)
+	customRegistry.MustRegister(CurrentConsecutiveMisses)
+	customRegistry.MustRegister(SlashingSignedBlocksWindow)
+	customRegistry.MustRegister(SlashingMinSignedPerWindow)
+	customRegistry.MustRegister(RemainingMissBudget)
+	customRegistry.MustRegister(BlocksUntilJail)
+	customRegistry.MustRegister(SecondsUntilJail)
+	customRegistry.MustRegister(SigningInfoMissedBlocks)
+	customRegistry.MustRegister(SigningInfoDivergence)
+	customRegistry.MustRegister(MissingHeights)
+	customRegistry.MustRegister(UnreachableHeights)
+	customRegistry.MustRegister(IsolatedMissCount)
+	customRegistry.MustRegister(NetworkWideMissCount)
+	customRegistry.MustRegister(OutOfActiveSetCount)
+	customRegistry.MustRegister(InActiveSet)
+	customRegistry.MustRegister(ValidatorVotingPower)
+	customRegistry.MustRegister(RPCEndpointActive)
+	customRegistry.MustRegister(RPCEndpointHealthScore)
+	customRegistry.MustRegister(RPCEndpointLatency)
+	customRegistry.MustRegister(RPCEndpointErrors)
+	customRegistry.MustRegister(NodeHealthy)
+	customRegistry.MustRegister(NodeCatchingUp)
+	customRegistry.MustRegister(NodeLagSeconds)
+	customRegistry.MustRegister(NodeHeightStalledSeconds)
+	customRegistry.MustRegister(BatchHeights)
+	customRegistry.MustRegister(BatchCommitLatency)
+	customRegistry.MustRegister(WorkerState)
+	customRegistry.MustRegister(WorkerRestarts)
 
 	return customRegistry, nil
 }
@@ -97,85 +340,204 @@ func MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	promhttp.Handler().ServeHTTP(w, r)
 }
 
-// Periodically update metrics every 15 seconds
-func StartMetricsUpdater(db *sql.DB, chainID string) {
+// Periodically update the metrics of chainID every 2 seconds until ctx is cancelled
+func StartMetricsUpdater(ctx context.Context, db db_utils.Store, chainID string) error {
 	logger.PostLog("INFO", fmt.Sprintf("Starting metrics updater for %s...", chainID))
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		updateMetrics(db, config_utils.ChainsData)
+	var chains []config_utils.ChainConfig
+	for _, chain := range config_utils.ChainsData {
+		if chain.ChainID == chainID {
+			chains = append(chains, chain)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			updateMetrics(db, chains)
+		}
 	}
 }
 
 // Collect and update Prometheus metrics
-func updateMetrics(db *sql.DB, chains []config_utils.ChainConfig) {
+func updateMetrics(db db_utils.Store, chains []config_utils.ChainConfig) {
 
 	for _, chain := range chains {
 
-		// Get diff between proposer timestamp and validator timestamp
-		averageTimeDiff, err := db_utils.GetTimestampDiff(db, chain.ChainID, chain.HexAddress)
+		// Get number of records in DB for this chain
+		numChainRecords, err := db.GetNumberOfRecordsForChain(chain.ChainID)
 		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetTimestampDiff", Success: false, Message: err.Error()})
+			fmt.Printf("Error fetching number of records for chain %s: %v\n", chain.ChainID, err)
 		}
+		NumberOfRecordsForChain.WithLabelValues(chain.ChainID).Set(float64(numChainRecords))
 
-		// Get the data for this chainID
-		count, latestBlockTimestamp, err := db_utils.GetAmountOfSignatureNotFound(db, chain.ChainID, chain.SigningWindow)
+		// Heights within the signing window the RPC nodes could no longer serve
+		_, latestHeight, err := db.GetStoredHeightRange(chain.ChainID)
 		if err != nil {
-			fmt.Printf("Error fetching data for chain %s: %v\n", chain.ChainID, err)
-			continue
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetStoredHeightRange", Success: false, Message: err.Error()})
 		}
-
-		// Get number of records in DB for this chain
-		numRecords, err := db_utils.GetNumberOfRecordsForChain(db, chain.ChainID)
+		unreachable, err := db.GetKnownGapCount(chain.ChainID, latestHeight-chain.SigningWindow+1, latestHeight)
 		if err != nil {
-			fmt.Printf("Error fetching number of records for chain %s: %v\n", chain.ChainID, err)
-		}
-		var window int = chain.SigningWindow
-		var signRate float64
-		if numRecords < chain.SigningWindow {
-			window = numRecords
-			signRate = float64(1) - (float64(count) / float64(window))
-		} else {
-			signRate = float64(1) - (float64(count) / float64(chain.SigningWindow))
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetKnownGapCount", Success: false, Message: err.Error()})
 		}
+		UnreachableHeights.WithLabelValues(chain.ChainID).Set(float64(unreachable))
 
-		if count == numRecords || count == chain.SigningWindow {
-			signRate = float64(0)
+		for _, validator := range chain.Validators() {
+			updateValidatorMetrics(db, chain, validator)
 		}
+	}
+}
 
-		// Parse the latestBlockTimestamp string to time.Time
-		latestBlockTime, err := time.Parse(time.RFC3339, latestBlockTimestamp)
-		if err != nil {
-			fmt.Printf("Error parsing timestamp for chain %s: %v\n", chain.ChainID, err)
-			continue
-		}
-		duration := time.Since(latestBlockTime)
-		roundedDuration := int(duration.Seconds())
+// Collect and update the Prometheus metrics of one validator on chain
+func updateValidatorMetrics(db db_utils.Store, chain config_utils.ChainConfig, validator config_utils.ValidatorConfig) {
+	address := validator.Address
+	name := validator.Name
 
-		// Convert the signing window to a string
-		signingWindowStr := fmt.Sprintf("%d", chain.SigningWindow)
+	// Get diff between proposer timestamp and validator timestamp
+	averageTimeDiff, err := db.GetTimestampDiff(chain.ChainID, address)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetTimestampDiff", Success: false, Message: err.Error()})
+	}
 
-		// Check number of proposed blocks in signing window
-		proposedBlocks, err := db_utils.GetNumberOfProposedBlocks(db, chain.ChainID, chain.HexAddress, window)
-		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetNumberOfProposedBlocks", Success: false, Message: err.Error()})
-		}
+	// Get the data for this chainID and address
+	count, latestBlockTimestamp, err := db.GetAmountOfSignatureNotFound(chain.ChainID, address, chain.SigningWindow)
+	if err != nil {
+		fmt.Printf("Error fetching data for chain %s address %s: %v\n", chain.ChainID, address, err)
+		return
+	}
+
+	// Get number of records in DB for this validator
+	numRecords, err := db.GetNumberOfRecordsForValidator(chain.ChainID, address)
+	if err != nil {
+		fmt.Printf("Error fetching number of records for chain %s address %s: %v\n", chain.ChainID, address, err)
+	}
+	var window int = chain.SigningWindow
+	if numRecords < chain.SigningWindow {
+		window = numRecords
+	}
+
+	// Heights where the validator was not in the active set are left out of the rate
+	outOfActiveSet, err := db.GetOutOfActiveSetCount(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetOutOfActiveSetCount", Success: false, Message: err.Error()})
+	}
+	activeWindow := window - outOfActiveSet
 
-		// Check number of empty proposed blocks in signing window
-		emptyBlocks, err := db_utils.GetNumberOfEmptyProposedBlocks(db, chain.ChainID, chain.HexAddress, window)
+	// Heights the RPC node could no longer serve are not in the DB, with fewer records than the signing window they are already left out
+	if window == chain.SigningWindow {
+		unreachable, err := db.GetUnreachableCount(chain.ChainID, address, window)
 		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetNumberOfEmptyProposedBlocks", Success: false, Message: err.Error()})
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetUnreachableCount", Success: false, Message: err.Error()})
 		}
+		activeWindow -= unreachable
+	}
+
+	var signRate float64
+	if activeWindow > 0 {
+		signRate = float64(1) - (float64(count) / float64(activeWindow))
+	}
+
+	if count == numRecords || count == chain.SigningWindow {
+		signRate = float64(0)
+	}
+
+	inActiveSet, votingPower, err := db.GetLatestActiveSetStatus(chain.ChainID, address)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetLatestActiveSetStatus", Success: false, Message: err.Error()})
+	}
+	var inActiveSetValue float64
+	if inActiveSet {
+		inActiveSetValue = 1
+	}
+
+	// Parse the latestBlockTimestamp string to time.Time
+	latestBlockTime, err := time.Parse(time.RFC3339, latestBlockTimestamp)
+	if err != nil {
+		fmt.Printf("Error parsing timestamp for chain %s: %v\n", chain.ChainID, err)
+		return
+	}
+	duration := time.Since(latestBlockTime)
+	roundedDuration := int(duration.Seconds())
+
+	// Convert the signing window to a string
+	signingWindowStr := fmt.Sprintf("%d", chain.SigningWindow)
+
+	// Check number of proposed blocks in signing window
+	proposedBlocks, err := db.GetNumberOfProposedBlocks(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetNumberOfProposedBlocks", Success: false, Message: err.Error()})
+	}
+
+	// Check number of empty proposed blocks in signing window
+	emptyBlocks, err := db.GetNumberOfEmptyProposedBlocks(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetNumberOfEmptyProposedBlocks", Success: false, Message: err.Error()})
+	}
+
+	// Break the window down by block id flag
+	committed, nilVoted, absent, err := db.GetBlockIDFlagCounts(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetBlockIDFlagCounts", Success: false, Message: err.Error()})
+	}
+
+	// Check the current streak of missed blocks
+	consecutiveMisses, err := db.GetCurrentConsecutiveMisses(chain.ChainID, address)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetCurrentConsecutiveMisses", Success: false, Message: err.Error()})
+	}
+
+	// Split the misses into isolated and network-wide ones
+	isolatedMisses, networkWideMisses, err := db.GetMissClassification(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetMissClassification", Success: false, Message: err.Error()})
+	}
+
+	// Check number of signatures that failed verification
+	invalidSignatures, err := db.GetInvalidSignatureCount(chain.ChainID, address, window)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetInvalidSignatureCount", Success: false, Message: err.Error()})
+	}
 
-		// Update Prometheus metrics
-		SignatureNotFoundCount.WithLabelValues(chain.ChainID, chain.HexAddress).Set(float64(count))
-		SigningRatePercentage.WithLabelValues(chain.ChainID, chain.HexAddress).Set(signRate)
-		SecondsSinceLatestBlockTimestamp.WithLabelValues(chain.ChainID).Set(float64(roundedDuration))
-		NumberOfRecordsForChain.WithLabelValues(chain.ChainID).Set(float64(numRecords))
-		SigningWindowSize.WithLabelValues(chain.ChainID).Set(float64(window))
-		NumberOfProposedBlocks.WithLabelValues(chain.ChainID, chain.HexAddress, signingWindowStr).Set(float64(proposedBlocks))
-		NumberOfEmptyProposedBlocks.WithLabelValues(chain.ChainID, chain.HexAddress, signingWindowStr).Set(float64(emptyBlocks))
-		LastBlockTimeDiff.WithLabelValues(chain.ChainID, chain.HexAddress).Set(float64(averageTimeDiff))
+	// Update Prometheus metrics
+	SignatureNotFoundCount.WithLabelValues(chain.ChainID, address, name).Set(float64(count))
+	SigningRatePercentage.WithLabelValues(chain.ChainID, address, name).Set(signRate)
+	SecondsSinceLatestBlockTimestamp.WithLabelValues(chain.ChainID).Set(float64(roundedDuration))
+	SigningWindowSize.WithLabelValues(chain.ChainID, address, name).Set(float64(window))
+	NumberOfProposedBlocks.WithLabelValues(chain.ChainID, address, name, signingWindowStr).Set(float64(proposedBlocks))
+	NumberOfEmptyProposedBlocks.WithLabelValues(chain.ChainID, address, name, signingWindowStr).Set(float64(emptyBlocks))
+	LastBlockTimeDiff.WithLabelValues(chain.ChainID, address, name).Set(float64(averageTimeDiff))
+	BlockIDFlagCount.WithLabelValues(chain.ChainID, address, name, "commit").Set(float64(committed))
+	BlockIDFlagCount.WithLabelValues(chain.ChainID, address, name, "nil").Set(float64(nilVoted))
+	BlockIDFlagCount.WithLabelValues(chain.ChainID, address, name, "absent").Set(float64(absent))
+	InvalidSignatureCount.WithLabelValues(chain.ChainID, address, name).Set(float64(invalidSignatures))
+	CurrentConsecutiveMisses.WithLabelValues(chain.ChainID, address, name).Set(float64(consecutiveMisses))
+	IsolatedMissCount.WithLabelValues(chain.ChainID, address, name).Set(float64(isolatedMisses))
+	NetworkWideMissCount.WithLabelValues(chain.ChainID, address, name).Set(float64(networkWideMisses))
+	OutOfActiveSetCount.WithLabelValues(chain.ChainID, address, name).Set(float64(outOfActiveSet))
+	InActiveSet.WithLabelValues(chain.ChainID, address, name).Set(inActiveSetValue)
+	ValidatorVotingPower.WithLabelValues(chain.ChainID, address, name).Set(float64(votingPower))
+
+	// Forecast the jail risk when the chain has a REST endpoint for the slashing params
+	forecast, hasForecast, err := jailForecast(db, chain, address)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "jailForecast", Success: false, Message: err.Error()})
+	}
+	if hasForecast {
+		blocksUntilJail, secondsUntilJail := math.Inf(1), math.Inf(1)
+		if forecast.BlocksUntilJail != nil {
+			blocksUntilJail = float64(*forecast.BlocksUntilJail)
+		}
+		if forecast.SecondsUntilJail != nil {
+			secondsUntilJail = *forecast.SecondsUntilJail
+		}
+		SlashingSignedBlocksWindow.WithLabelValues(chain.ChainID).Set(float64(forecast.SignedBlocksWindow))
+		SlashingMinSignedPerWindow.WithLabelValues(chain.ChainID).Set(forecast.MinSignedPerWindow)
+		RemainingMissBudget.WithLabelValues(chain.ChainID, address, name).Set(float64(forecast.RemainingMissBudget))
+		BlocksUntilJail.WithLabelValues(chain.ChainID, address, name).Set(blocksUntilJail)
+		SecondsUntilJail.WithLabelValues(chain.ChainID, address, name).Set(secondsUntilJail)
 	}
 }
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultReconcileInterval is used when reconcile_interval is not set
+const defaultReconcileInterval = 5 * time.Minute
+
+// Reconciliation is the chains missed_blocks_counter of a validator next to the misses counted from the DB over the same window
+type Reconciliation struct {
+	Height      int
+	ChainMissed int
+	LocalMissed int
+	// in-set heights found in the DB within the window out of the Expected heights the window can span,
+	// fewer means the DB has gaps or does not reach back far enough, or the validator was out of the active set
+	LocalRecords int
+	Expected     int
+}
+
+// Divergence is the number of misses the chain counted that are not in the DB, negative if the DB has more
+func (r Reconciliation) Divergence() int {
+	return r.ChainMissed - r.LocalMissed
+}
+
+// StartReconciler periodically compares the x/slashing signing info of every validator of chainID with the DB until ctx is cancelled.
+// It does nothing for chains without a REST endpoint.
+func StartReconciler(ctx context.Context, db db_utils.Store, chainID string) error {
+	chain, ok := config_utils.GetChain(chainID)
+	if !ok || chain.RestAddress == "" {
+		return nil
+	}
+
+	interval := defaultReconcileInterval
+	if chain.ReconcileInterval != "" {
+		parsed, err := time.ParseDuration(chain.ReconcileInterval)
+		if err != nil {
+			return &RPCError{Kind: ErrConfig, Op: "StartReconciler", Err: fmt.Errorf("invalid reconcile_interval %q: %v", chain.ReconcileInterval, err)}
+		}
+		interval = parsed
+	}
+
+	logger.PostLog("INFO", fmt.Sprintf("Starting signing info reconciler for %s every %s...", chainID, interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reconcileChain(db, chain)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func reconcileChain(db db_utils.Store, chain config_utils.ChainConfig) {
+	prefix := chain.Bech32Prefix
+	if prefix == "" {
+		var err error
+		prefix, err = GetBech32Prefix(chain.ChainID, chain.RestAddress)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleReconciler{ChainID: chain.ChainID, Message: fmt.Sprintf("failed to get bech32 prefix, set bech32_prefix: %v", err)})
+			return
+		}
+	}
+
+	for _, validator := range chain.Validators() {
+		result, err := reconcileValidator(db, chain, prefix, validator.Address)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleReconciler{ChainID: chain.ChainID, Address: validator.Address, Message: err.Error()})
+			continue
+		}
+		if result.Height == 0 {
+			// nothing stored yet
+			continue
+		}
+
+		SigningInfoMissedBlocks.WithLabelValues(chain.ChainID, validator.Address, validator.Name).Set(float64(result.ChainMissed))
+		SigningInfoDivergence.WithLabelValues(chain.ChainID, validator.Address, validator.Name).Set(float64(result.Divergence()))
+
+		entry := logger.ModuleReconciler{
+			ChainID:     chain.ChainID,
+			Address:     validator.Address,
+			Height:      result.Height,
+			ChainMissed: result.ChainMissed,
+			LocalMissed: result.LocalMissed,
+			Divergence:  result.Divergence(),
+			Message:     fmt.Sprintf("counted %d in-set heights of the %d heights the slashing window can span", result.LocalRecords, result.Expected),
+		}
+		if result.Divergence() != 0 {
+			logger.PostLog("WARN", entry)
+		} else {
+			logger.PostLog("INFO", entry)
+		}
+	}
+}
+
+// reconcileValidator compares the signing info of address as of the latest stored height with the DB.
+// The chain only advances the window at heights the validator is in the active set and restarts it at start_height.
+func reconcileValidator(db db_utils.Store, chain config_utils.ChainConfig, prefix string, address string) (Reconciliation, error) {
+	height, err := db.GetLatestStoredHeight(chain.ChainID, address)
+	if err != nil || height == 0 {
+		return Reconciliation{}, err
+	}
+
+	params, err := GetSlashingParams(chain.ChainID, chain.RestAddress)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	consAddress, err := ValconsAddress(prefix, address)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	// query the state at the latest stored block so both sides saw the same commits
+	info, err := GetSigningInfo(chain.ChainID, chain.RestAddress, consAddress, height)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+
+	localMissed, localRecords, err := db.GetSlashingWindowMisses(chain.ChainID, address, info.StartHeight, height, params.SignedBlocksWindow)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+
+	expected := params.SignedBlocksWindow
+	if signed := height - info.StartHeight + 1; signed < expected {
+		expected = signed
+	}
+	return Reconciliation{
+		Height:       height,
+		ChainMissed:  info.MissedBlocksCounter,
+		LocalMissed:  localMissed,
+		LocalRecords: localRecords,
+		Expected:     expected,
+	}, nil
+}
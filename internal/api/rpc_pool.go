@@ -0,0 +1,220 @@
+package api
+
+import (
+	"cometbftsignrate/internal/logger"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// weight of the newest sample in the latency moving average
+const latencyEWMAWeight = 0.3
+
+type hostHealth struct {
+	address           string
+	latency           time.Duration
+	consecutiveErrors int
+	catchingUp        bool
+	limiter           *rateLimiter
+}
+
+// HostPool keeps track of the health of every RPC endpoint configured for a chain
+// and routes requests to the healthiest one, failing over to the next on errors.
+type HostPool struct {
+	chainID string
+
+	mu     sync.Mutex
+	hosts  []*hostHealth
+	active string
+}
+
+func NewHostPool(chainID string, hosts []string) *HostPool {
+	pool := &HostPool{chainID: chainID}
+	for _, host := range hosts {
+		pool.hosts = append(pool.hosts, &hostHealth{address: host})
+	}
+	if len(hosts) > 0 {
+		pool.active = hosts[0]
+	}
+	pool.updateMetrics()
+	return pool
+}
+
+// SetRateLimit caps the requests sent to each host of the pool, 0 removes the limit
+func (p *HostPool) SetRateLimit(requestsPerSecond float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.hosts {
+		h.limiter = newRateLimiter(requestsPerSecond)
+	}
+}
+
+// waitForHost blocks until the rate limit of host allows another request
+func (p *HostPool) waitForHost(host string) {
+	p.mu.Lock()
+	var limiter *rateLimiter
+	for _, h := range p.hosts {
+		if h.address == host {
+			limiter = h.limiter
+		}
+	}
+	p.mu.Unlock()
+	limiter.wait()
+}
+
+// score rates a host between 0 and 100, higher is healthier
+func (h *hostHealth) score() float64 {
+	score := 100.0
+
+	// every 10ms of average latency costs a point, up to 30
+	latencyPenalty := float64(h.latency.Milliseconds()) / 10
+	if latencyPenalty > 30 {
+		latencyPenalty = 30
+	}
+	score -= latencyPenalty
+
+	// every consecutive error costs 20 points, up to 60
+	errorPenalty := float64(h.consecutiveErrors) * 20
+	if errorPenalty > 60 {
+		errorPenalty = 60
+	}
+	score -= errorPenalty
+
+	// a node that is still syncing serves stale data
+	if h.catchingUp {
+		score -= 10
+	}
+
+	return score
+}
+
+// Hosts returns the host addresses ordered from healthiest to least healthy
+func (p *HostPool) Hosts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ranked := make([]*hostHealth, len(p.hosts))
+	copy(ranked, p.hosts)
+	// stable sort keeps the config order for hosts with the same score
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score() > ranked[j].score()
+	})
+
+	addresses := make([]string, 0, len(ranked))
+	for _, h := range ranked {
+		addresses = append(addresses, h.address)
+	}
+	return addresses
+}
+
+// Best returns the healthiest host
+func (p *HostPool) Best() string {
+	hosts := p.Hosts()
+	if len(hosts) == 0 {
+		return ""
+	}
+	return hosts[0]
+}
+
+// Do runs request against the healthiest host and fails over to the next one until a request succeeds.
+// Returns the error of the last host tried if all of them fail.
+func (p *HostPool) Do(operation string, request func(host string) error) error {
+	hosts := p.Hosts()
+	if len(hosts) == 0 {
+		return fmt.Errorf("no RPC hosts configured for %s", p.chainID)
+	}
+
+	var err error
+	for _, host := range hosts {
+		p.waitForHost(host)
+		start := time.Now()
+		err = request(host)
+		p.record(host, time.Since(start), err)
+		if err == nil {
+			p.setActive(host)
+			return nil
+		}
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: p.chainID, Operation: operation, Success: false, Message: fmt.Sprintf("Request to %s failed, trying next host: %v", host, err)})
+	}
+	return err
+}
+
+// Probe queries /status on every host so hosts that recovered can regain their rank
+func (p *HostPool) Probe() {
+	for _, host := range p.Hosts() {
+		p.waitForHost(host)
+		start := time.Now()
+		syncInfo, err := GetNodeStatus(p.chainID, host)
+		p.record(host, time.Since(start), err)
+		if err == nil {
+			p.ReportCatchingUp(host, syncInfo.CatchingUp)
+		}
+	}
+}
+
+// ReportCatchingUp records the sync state a host reported in /status
+func (p *HostPool) ReportCatchingUp(host string, catchingUp bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, h := range p.hosts {
+		if h.address == host {
+			h.catchingUp = catchingUp
+		}
+	}
+	p.updateMetricsLocked()
+}
+
+func (p *HostPool) record(host string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, h := range p.hosts {
+		if h.address != host {
+			continue
+		}
+		if err != nil {
+			h.consecutiveErrors++
+			RPCEndpointErrors.WithLabelValues(p.chainID, host).Inc()
+			continue
+		}
+		h.consecutiveErrors = 0
+		if h.latency == 0 {
+			h.latency = latency
+		} else {
+			h.latency = time.Duration(latencyEWMAWeight*float64(latency) + (1-latencyEWMAWeight)*float64(h.latency))
+		}
+	}
+	p.updateMetricsLocked()
+}
+
+// setActive marks host as the one serving requests for the chain
+func (p *HostPool) setActive(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active != host {
+		logger.PostLog("INFO", logger.ModuleHTTP{ChainID: p.chainID, Operation: "HostPool", Success: true, Message: fmt.Sprintf("Now serving requests from %s", host)})
+		p.active = host
+		p.updateMetricsLocked()
+	}
+}
+
+func (p *HostPool) updateMetrics() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updateMetricsLocked()
+}
+
+func (p *HostPool) updateMetricsLocked() {
+	for _, h := range p.hosts {
+		active := 0.0
+		if h.address == p.active {
+			active = 1
+		}
+		RPCEndpointActive.WithLabelValues(p.chainID, h.address).Set(active)
+		RPCEndpointHealthScore.WithLabelValues(p.chainID, h.address).Set(h.score())
+		RPCEndpointLatency.WithLabelValues(p.chainID, h.address).Set(float64(h.latency.Milliseconds()))
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"cometbftsignrate/internal/bech32"
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slashing params rarely change, they are fetched again after this long
+const slashingParamsTTL = 10 * time.Minute
+
+type SlashingParamsResponse struct {
+	Params struct {
+		SignedBlocksWindow   string `json:"signed_blocks_window"`
+		MinSignedPerWindow   string `json:"min_signed_per_window"`
+		DowntimeJailDuration string `json:"downtime_jail_duration"`
+	} `json:"params"`
+}
+
+// SlashingParams are the x/slashing liveness params of a chain
+type SlashingParams struct {
+	SignedBlocksWindow int
+	MinSignedPerWindow float64
+}
+
+// MaxMissedBlocks returns how many blocks a validator may miss within SignedBlocksWindow before it is jailed
+func (p SlashingParams) MaxMissedBlocks() int {
+	minSigned := int(math.Round(p.MinSignedPerWindow * float64(p.SignedBlocksWindow)))
+	return p.SignedBlocksWindow - minSigned
+}
+
+type cachedSlashingParams struct {
+	params    SlashingParams
+	fetchedAt time.Time
+}
+
+var (
+	slashingParamsMu    sync.Mutex
+	slashingParamsCache = make(map[string]cachedSlashingParams)
+)
+
+// GetSlashingParams returns the slashing params of chainID from the Cosmos SDK REST endpoint restHost, cached for slashingParamsTTL
+func GetSlashingParams(chainID string, restHost string) (SlashingParams, error) {
+	slashingParamsMu.Lock()
+	cached, ok := slashingParamsCache[chainID]
+	slashingParamsMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < slashingParamsTTL {
+		return cached.params, nil
+	}
+
+	var response SlashingParamsResponse
+	host := strings.TrimSuffix(restHost, "/")
+	if err := rpcGet("getSlashingParams", host, "/cosmos/slashing/v1beta1/params", 0, &response); err != nil {
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chainID, Operation: "getSlashingParams", Success: false, Message: err.Error()})
+		// a stale value is better than none while the endpoint is down
+		if ok {
+			return cached.params, nil
+		}
+		return SlashingParams{}, err
+	}
+
+	params, err := parseSlashingParams(response)
+	if err != nil {
+		return SlashingParams{}, &RPCError{Kind: ErrMalformedResponse, Op: "getSlashingParams", Host: host, Err: err}
+	}
+
+	slashingParamsMu.Lock()
+	slashingParamsCache[chainID] = cachedSlashingParams{params: params, fetchedAt: time.Now()}
+	slashingParamsMu.Unlock()
+	return params, nil
+}
+
+func parseSlashingParams(response SlashingParamsResponse) (SlashingParams, error) {
+	window, err := strconv.Atoi(response.Params.SignedBlocksWindow)
+	if err != nil || window <= 0 {
+		return SlashingParams{}, fmt.Errorf("invalid signed_blocks_window %q", response.Params.SignedBlocksWindow)
+	}
+	minSigned, err := strconv.ParseFloat(response.Params.MinSignedPerWindow, 64)
+	if err != nil || minSigned < 0 || minSigned > 1 {
+		return SlashingParams{}, fmt.Errorf("invalid min_signed_per_window %q", response.Params.MinSignedPerWindow)
+	}
+	return SlashingParams{SignedBlocksWindow: window, MinSignedPerWindow: minSigned}, nil
+}
+
+// JailForecast is how close a validator is to being jailed for downtime
+type JailForecast struct {
+	SignedBlocksWindow  int     `json:"signedBlocksWindow"`
+	MinSignedPerWindow  float64 `json:"minSignedPerWindow"`
+	MaxMissedBlocks     int     `json:"maxMissedBlocks"`
+	MissedBlocks        int     `json:"missedBlocks"`
+	RecordsInWindow     int     `json:"recordsInWindow"`
+	RemainingMissBudget int     `json:"remainingMissBudget"`
+	MissRate            float64 `json:"missRate"`
+	// nil if the validator is not jailed at the current miss rate
+	BlocksUntilJail  *int     `json:"blocksUntilJail"`
+	SecondsUntilJail *float64 `json:"secondsUntilJail"`
+}
+
+// NewJailForecast projects when the validator is jailed if it keeps missing at the rate of the stored window.
+// missed counts absent votes only, x/slashing treats a nil vote as signed.
+func NewJailForecast(params SlashingParams, missed int, records int, averageBlockTime float64) JailForecast {
+	forecast := JailForecast{
+		SignedBlocksWindow: params.SignedBlocksWindow,
+		MinSignedPerWindow: params.MinSignedPerWindow,
+		MaxMissedBlocks:    params.MaxMissedBlocks(),
+		MissedBlocks:       missed,
+		RecordsInWindow:    records,
+	}
+	forecast.RemainingMissBudget = forecast.MaxMissedBlocks - missed
+	if forecast.RemainingMissBudget < 0 {
+		forecast.RemainingMissBudget = 0
+	}
+
+	if records > 0 {
+		forecast.MissRate = float64(missed) / float64(records)
+	}
+	// at a steady rate the misses in the sliding window settle at MissRate * SignedBlocksWindow, below the limit it never jails
+	if forecast.MissRate > 0 && forecast.MissRate*float64(params.SignedBlocksWindow) > float64(forecast.MaxMissedBlocks) {
+		// the validator is jailed by the first miss over the budget
+		blocks := int(math.Ceil(float64(forecast.RemainingMissBudget+1) / forecast.MissRate))
+		forecast.BlocksUntilJail = &blocks
+		if averageBlockTime > 0 {
+			seconds := float64(blocks) * averageBlockTime
+			forecast.SecondsUntilJail = &seconds
+		}
+	}
+	return forecast
+}
+
+// blockTimeSamples is the number of stored heights the average block time is taken over
+const blockTimeSamples = 100
+
+// jailForecast builds the jail forecast of address from the stored commits, false if the chain has no REST endpoint configured
+func jailForecast(db db_utils.Store, chain config_utils.ChainConfig, address string) (JailForecast, bool, error) {
+	if chain.RestAddress == "" {
+		return JailForecast{}, false, nil
+	}
+	params, err := GetSlashingParams(chain.ChainID, chain.RestAddress)
+	if err != nil {
+		return JailForecast{}, false, err
+	}
+	absent, records, err := db.GetAbsentCount(chain.ChainID, address, params.SignedBlocksWindow)
+	if err != nil {
+		return JailForecast{}, false, err
+	}
+	averageBlockTime, err := db.GetAverageBlockTime(chain.ChainID, address, blockTimeSamples)
+	if err != nil {
+		return JailForecast{}, false, err
+	}
+	return NewJailForecast(params, absent, records, averageBlockTime), true, nil
+}
+
+type SigningInfoResponse struct {
+	ValSigningInfo struct {
+		Address             string `json:"address"`
+		StartHeight         string `json:"start_height"`
+		IndexOffset         string `json:"index_offset"`
+		JailedUntil         string `json:"jailed_until"`
+		Tombstoned          bool   `json:"tombstoned"`
+		MissedBlocksCounter string `json:"missed_blocks_counter"`
+	} `json:"val_signing_info"`
+}
+
+// SigningInfo is the x/slashing liveness state the chain keeps for a validator
+type SigningInfo struct {
+	Address             string
+	StartHeight         int
+	Tombstoned          bool
+	MissedBlocksCounter int
+}
+
+type Bech32PrefixResponse struct {
+	Bech32Prefix string `json:"bech32_prefix"`
+}
+
+var (
+	bech32PrefixMu    sync.Mutex
+	bech32PrefixCache = make(map[string]string)
+)
+
+// GetBech32Prefix returns the account address prefix of chainID (e.g. cosmos) from /cosmos/auth/v1beta1/bech32
+func GetBech32Prefix(chainID string, restHost string) (string, error) {
+	bech32PrefixMu.Lock()
+	prefix, ok := bech32PrefixCache[chainID]
+	bech32PrefixMu.Unlock()
+	if ok {
+		return prefix, nil
+	}
+
+	var response Bech32PrefixResponse
+	host := strings.TrimSuffix(restHost, "/")
+	if err := rpcGet("getBech32Prefix", host, "/cosmos/auth/v1beta1/bech32", 0, &response); err != nil {
+		return "", err
+	}
+	if response.Bech32Prefix == "" {
+		return "", &RPCError{Kind: ErrMalformedResponse, Op: "getBech32Prefix", Host: host, Err: fmt.Errorf("empty bech32_prefix")}
+	}
+
+	bech32PrefixMu.Lock()
+	bech32PrefixCache[chainID] = response.Bech32Prefix
+	bech32PrefixMu.Unlock()
+	return response.Bech32Prefix, nil
+}
+
+// ValconsAddress encodes the HEX consensus address as a bech32 <prefix>valcons address
+func ValconsAddress(prefix string, hexAddress string) (string, error) {
+	address, err := hex.DecodeString(hexAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid HEX address %s: %v", hexAddress, err)
+	}
+	return bech32.Encode(prefix+"valcons", address)
+}
+
+// GetSigningInfo returns the signing info of the valcons address consAddress as of height, the latest state if height is 0
+func GetSigningInfo(chainID string, restHost string, consAddress string, height int) (SigningInfo, error) {
+	var response SigningInfoResponse
+	host := strings.TrimSuffix(restHost, "/")
+
+	// the gRPC gateway serves historical state through this header
+	header := http.Header{}
+	if height > 0 {
+		header.Set("x-cosmos-block-height", strconv.Itoa(height))
+	}
+	if err := rpcGetWithHeader("getSigningInfo", host, "/cosmos/slashing/v1beta1/signing_infos/"+consAddress, height, header, &response); err != nil {
+		return SigningInfo{}, err
+	}
+
+	info := response.ValSigningInfo
+	startHeight, err := strconv.Atoi(info.StartHeight)
+	if err != nil {
+		return SigningInfo{}, &RPCError{Kind: ErrMalformedResponse, Op: "getSigningInfo", Host: host, Height: height, Err: fmt.Errorf("invalid start_height %q", info.StartHeight)}
+	}
+	missed, err := strconv.Atoi(info.MissedBlocksCounter)
+	if err != nil {
+		return SigningInfo{}, &RPCError{Kind: ErrMalformedResponse, Op: "getSigningInfo", Host: host, Height: height, Err: fmt.Errorf("invalid missed_blocks_counter %q", info.MissedBlocksCounter)}
+	}
+	return SigningInfo{Address: info.Address, StartHeight: startHeight, Tombstoned: info.Tombstoned, MissedBlocksCounter: missed}, nil
+}
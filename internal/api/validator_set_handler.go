@@ -0,0 +1,193 @@
+package api
+
+import (
+	"cometbftsignrate/internal/config_utils"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Reasons a validator set change is flagged as affecting the validator
+const (
+	ChangeEnteredActiveSet = "entered_active_set"
+	ChangeLeftActiveSet    = "left_active_set"
+	ChangeVotingPower      = "voting_power_changed"
+	ChangeRank             = "rank_changed"
+)
+
+type VotingPowerPoint struct {
+	Height           int     `json:"height"`
+	Timestamp        string  `json:"timestamp"`
+	ValidatorsHash   string  `json:"validatorsHash"`
+	InActiveSet      bool    `json:"inActiveSet"`
+	VotingPower      int64   `json:"votingPower"`
+	Rank             int     `json:"rank"`
+	Share            float64 `json:"share"`
+	NumValidators    int     `json:"numValidators"`
+	TotalVotingPower int64   `json:"totalVotingPower"`
+}
+
+type ValidatorSetChange struct {
+	Height                 int      `json:"height"`
+	Timestamp              string   `json:"timestamp"`
+	ValidatorsHash         string   `json:"validatorsHash"`
+	PreviousValidatorsHash string   `json:"previousValidatorsHash"`
+	Affected               bool     `json:"affected"`
+	Reasons                []string `json:"reasons"`
+	PreviousVotingPower    int64    `json:"previousVotingPower"`
+	VotingPower            int64    `json:"votingPower"`
+	PreviousRank           int      `json:"previousRank"`
+	Rank                   int      `json:"rank"`
+	PreviousShare          float64  `json:"previousShare"`
+	Share                  float64  `json:"share"`
+	NumValidators          int      `json:"numValidators"`
+	TotalVotingPower       int64    `json:"totalVotingPower"`
+}
+
+// VotingPowerHandler returns the voting power, rank and share of a validator in every validator set snapshot
+func VotingPowerHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
+	chainID, validator, history, ok := votingPowerHistory(db, w, r)
+	if !ok {
+		return
+	}
+
+	points := make([]VotingPowerPoint, 0, len(history))
+	for _, snapshot := range history {
+		points = append(points, votingPowerPoint(snapshot))
+	}
+
+	response := map[string]interface{}{
+		"chainID":   chainID,
+		"address":   validator.Address,
+		"name":      validator.Name,
+		"snapshots": points,
+	}
+
+	// Set response headers and encode response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "VotingPower HTTP Request", Success: true})
+}
+
+// ValidatorSetChangesHandler lists every validator set change and flags the ones that changed the validators power, rank or membership
+func ValidatorSetChangesHandler(db db_utils.Store, w http.ResponseWriter, r *http.Request) {
+	chainID, validator, history, ok := votingPowerHistory(db, w, r)
+	if !ok {
+		return
+	}
+	affectedOnly := r.URL.Query().Get("affectedOnly") == "true"
+
+	changes := []ValidatorSetChange{}
+	for i := 1; i < len(history); i++ {
+		previous, current := votingPowerPoint(history[i-1]), votingPowerPoint(history[i])
+
+		reasons := []string{}
+		switch {
+		case !previous.InActiveSet && current.InActiveSet:
+			reasons = append(reasons, ChangeEnteredActiveSet)
+		case previous.InActiveSet && !current.InActiveSet:
+			reasons = append(reasons, ChangeLeftActiveSet)
+		}
+		if previous.InActiveSet && current.InActiveSet {
+			if previous.VotingPower != current.VotingPower {
+				reasons = append(reasons, ChangeVotingPower)
+			}
+			if previous.Rank != current.Rank {
+				reasons = append(reasons, ChangeRank)
+			}
+		}
+
+		affected := len(reasons) > 0
+		if affectedOnly && !affected {
+			continue
+		}
+		changes = append(changes, ValidatorSetChange{
+			Height:                 current.Height,
+			Timestamp:              current.Timestamp,
+			ValidatorsHash:         current.ValidatorsHash,
+			PreviousValidatorsHash: previous.ValidatorsHash,
+			Affected:               affected,
+			Reasons:                reasons,
+			PreviousVotingPower:    previous.VotingPower,
+			VotingPower:            current.VotingPower,
+			PreviousRank:           previous.Rank,
+			Rank:                   current.Rank,
+			PreviousShare:          previous.Share,
+			Share:                  current.Share,
+			NumValidators:          current.NumValidators,
+			TotalVotingPower:       current.TotalVotingPower,
+		})
+	}
+
+	response := map[string]interface{}{
+		"chainID": chainID,
+		"address": validator.Address,
+		"name":    validator.Name,
+		"changes": changes,
+	}
+
+	// Set response headers and encode response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "ValidatorSetChanges HTTP Request", Success: true})
+}
+
+// votingPowerHistory parses the shared query parameters and loads the snapshots, it writes the error response itself
+func votingPowerHistory(db db_utils.Store, w http.ResponseWriter, r *http.Request) (string, config_utils.ValidatorConfig, []db_utils.VotingPowerSnapshot, bool) {
+	chainID := r.URL.Query().Get("chainID")
+	if chainID == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return "", config_utils.ValidatorConfig{}, nil, false
+	}
+
+	validator, err := resolveValidator(chainID, r.URL.Query().Get("address"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return "", config_utils.ValidatorConfig{}, nil, false
+	}
+
+	fromHeight, toHeight := 0, 0
+	if value := r.URL.Query().Get("fromHeight"); value != "" {
+		if fromHeight, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid fromHeight", http.StatusBadRequest)
+			return "", config_utils.ValidatorConfig{}, nil, false
+		}
+	}
+	if value := r.URL.Query().Get("toHeight"); value != "" {
+		if toHeight, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid toHeight", http.StatusBadRequest)
+			return "", config_utils.ValidatorConfig{}, nil, false
+		}
+	}
+
+	history, err := db.GetVotingPowerHistory(chainID, validator.Address, fromHeight, toHeight)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", config_utils.ValidatorConfig{}, nil, false
+	}
+	return chainID, validator, history, true
+}
+
+func votingPowerPoint(snapshot db_utils.VotingPowerSnapshot) VotingPowerPoint {
+	var share float64
+	if snapshot.TotalVotingPower > 0 {
+		share = float64(snapshot.VotingPower) / float64(snapshot.TotalVotingPower)
+	}
+	return VotingPowerPoint{
+		Height:           snapshot.Height,
+		Timestamp:        snapshot.Timestamp,
+		ValidatorsHash:   snapshot.ValidatorsHash,
+		InActiveSet:      snapshot.InActiveSet,
+		VotingPower:      snapshot.VotingPower,
+		Rank:             snapshot.Rank,
+		Share:            share,
+		NumValidators:    snapshot.NumValidators,
+		TotalVotingPower: snapshot.TotalVotingPower,
+	}
+}
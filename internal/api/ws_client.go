@@ -0,0 +1,165 @@
+package api
+
+import (
+	"cometbftsignrate/internal/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type NewBlockEvent struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+	Result struct {
+		Data struct {
+			Type  string `json:"type"`
+			Value struct {
+				Block Block `json:"block"`
+			} `json:"value"`
+		} `json:"data"`
+	} `json:"result"`
+}
+
+// websocketURL converts the RPC host (http/https) to the nodes websocket endpoint
+func websocketURL(host string) (string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = "/websocket"
+	return u.String(), nil
+}
+
+// websocket keepalive: we ping the node ourselves, so a chain with long block times or a proxy dropping the
+// nodes pings still keeps the connection alive. A connection without any frame for wsReadTimeout is dead.
+const (
+	wsPingInterval = 20 * time.Second
+	wsReadTimeout  = 60 * time.Second
+)
+
+// SubscribeNewBlocks subscribes to the NewBlock events of the healthiest host of pool and sends every block to the
+// blocks channel. Dial and read errors are recorded in the pool so the next subscription fails over to another host.
+// It only returns once the connection is lost or the context is cancelled.
+func SubscribeNewBlocks(ctx context.Context, pool *HostPool, blocks chan<- Block) error {
+	chainID := pool.chainID
+	host := pool.Best()
+	if host == "" {
+		return fmt.Errorf("no RPC hosts configured for %s", chainID)
+	}
+	wsURL, err := websocketURL(host)
+	if err != nil {
+		return fmt.Errorf("invalid host address %s: %v", host, err)
+	}
+
+	start := time.Now()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	pool.record(host, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", wsURL, err)
+	}
+	defer conn.Close()
+	pool.setActive(host)
+
+	// every ping or pong proves the connection is alive, not only block events
+	extendDeadline := func() {
+		conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	}
+	conn.SetPongHandler(func(string) error {
+		extendDeadline()
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		extendDeadline()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	// close the connection when the context is cancelled so ReadMessage returns, ping the node until then
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		pingTicker := time.NewTicker(wsPingInterval)
+		defer pingTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
+				return
+			case <-pingTicker.C:
+				// a failed ping surfaces as a read error once the deadline passes
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+			}
+		}
+	}()
+
+	subscribe := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "subscribe",
+		"id":      1,
+		"params":  map[string]string{"query": "tm.event='NewBlock'"},
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		pool.record(host, 0, err)
+		return fmt.Errorf("failed to subscribe to NewBlock events: %v", err)
+	}
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chainID, Operation: "SubscribeNewBlocks", Success: true, Message: fmt.Sprintf("Subscribed to NewBlock events on %s", wsURL)})
+
+	for {
+		extendDeadline()
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pool.record(host, 0, err)
+			return fmt.Errorf("websocket read error from %s: %v", host, err)
+		}
+
+		var event NewBlockEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			return fmt.Errorf("failed to decode websocket message: %v", err)
+		}
+		if event.Error != nil {
+			pool.record(host, 0, fmt.Errorf("subscription error: %s", event.Error.Message))
+			return fmt.Errorf("subscription error: %s %s", event.Error.Message, event.Error.Data)
+		}
+
+		// the subscribe confirmation has an empty result
+		if event.Result.Data.Type != "tendermint/event/NewBlock" {
+			continue
+		}
+
+		select {
+		case blocks <- event.Result.Data.Value.Block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// BlockHeight returns the height from the block header
+func BlockHeight(block Block) (int, error) {
+	return strconv.Atoi(block.Header.Height)
+}
@@ -0,0 +1,81 @@
+// Package bech32 encodes addresses in the bech32 format (BIP-173) used by Cosmos SDK chains
+package bech32
+
+import (
+	"fmt"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data from fromBits to toBits per byte, padding the last group with zeros
+func convertBits(data []byte, fromBits uint, toBits uint) []byte {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	converted := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, b := range data {
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+	if bits > 0 {
+		converted = append(converted, byte(acc<<(toBits-bits)&maxValue))
+	}
+	return converted
+}
+
+// Encode returns the bech32 encoding of data with the human readable part hrp
+func Encode(hrp string, data []byte) (string, error) {
+	if hrp == "" {
+		return "", fmt.Errorf("empty bech32 prefix")
+	}
+	hrp = strings.ToLower(hrp)
+
+	values := convertBits(data, 8, 5)
+	checksumInput := append(hrpExpand(hrp), values...)
+	checksumInput = append(checksumInput, 0, 0, 0, 0, 0, 0)
+	checksum := polymod(checksumInput) ^ 1
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range values {
+		sb.WriteByte(charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(charset[(checksum>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
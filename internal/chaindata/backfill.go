@@ -0,0 +1,114 @@
+package chaindata
+
+import (
+	"context"
+	"fmt"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// Backfill stores every height in [from, to] that is not stored yet, resuming after the checkpoint of an earlier run
+// of the same range. The checkpoint is moved in the transaction of each batch of heights, so an interrupted run loses
+// at most the uncommitted batch. Backfilled heights are not grouped into incidents.
+// Returns the checkpoint after the run.
+func Backfill(ctx context.Context, chain Chain, db db_utils.Store, from int, to int) (db_utils.BackfillCheckpoint, error) {
+	if from < 1 || to < from {
+		return db_utils.BackfillCheckpoint{}, fmt.Errorf("invalid backfill range %d-%d", from, to)
+	}
+
+	checkpoint, ok, err := db.GetBackfillCheckpoint(chain.ChainID, from, to)
+	if err != nil {
+		return db_utils.BackfillCheckpoint{}, err
+	}
+	if !ok {
+		checkpoint = db_utils.BackfillCheckpoint{ChainID: chain.ChainID, FromHeight: from, ToHeight: to, NextHeight: from}
+	}
+	if checkpoint.Done() {
+		return checkpoint, nil
+	}
+	if ok {
+		logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Height: checkpoint.NextHeight, Success: true, Message: fmt.Sprintf("Resuming backfill of %d-%d at height %d", from, to, checkpoint.NextHeight)})
+	}
+
+	// heights the node has pruned are recorded as a known gap and skipped
+	status, err := api.GetChainStatus(chain.ChainID, chain.Pool)
+	if err != nil {
+		return checkpoint, err
+	}
+	start, err := clampToEarliest(chain, db, status, checkpoint.NextHeight, to)
+	if err != nil {
+		return checkpoint, err
+	}
+	checkpoint.NextHeight = start
+	if checkpoint.Done() {
+		return checkpoint, db.SaveBackfillCheckpoint(checkpoint)
+	}
+
+	fetch := blockFetcher(chain)
+	batch := newBlockBatch(chain, db, checkpoint.NextHeight-1)
+	batch.beforeCommit = func(tx db_utils.BlockWriter, height int) error {
+		next := checkpoint
+		next.NextHeight = height + 1
+		return tx.SaveBackfillCheckpoint(next)
+	}
+	store := func(height int, block api.Block) error {
+		return batch.store(height, block, false)
+	}
+
+	_, err = fetchRange(ctx, checkpoint.NextHeight, to+1, chain.Concurrency, fetch, store)
+	if commitErr := batch.commit(); commitErr != nil && err == nil {
+		err = commitErr
+	}
+	checkpoint.NextHeight = batch.committedHeight + 1
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Height: checkpoint.NextHeight, Success: false, Message: err.Error()})
+	}
+	return checkpoint, err
+}
+
+// RunPendingBackfills resumes the unfinished backfills of the chain, e.g. those scheduled by a schema migration.
+// With pruning enabled a range the pruner would delete again is marked done without fetching it. With liveOnly
+// ranges starting below the earliest height of the chains nodes are left for the backfill command and an archive node,
+// Backfill would otherwise record them as known gaps.
+func RunPendingBackfills(ctx context.Context, chain Chain, db db_utils.Store, liveOnly bool) error {
+	pending, err := db.GetPendingBackfills(chain.ChainID)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	var retained db_utils.HeightRange
+	if chain.PruningEnabled {
+		if retained, _, err = retainedRange(chain, db); err != nil {
+			return err
+		}
+	}
+	earliest := 0
+	if liveOnly {
+		status, err := api.GetChainStatus(chain.ChainID, chain.Pool)
+		if err != nil {
+			return err
+		}
+		earliest = status.EarliestHeight
+	}
+	for _, checkpoint := range pending {
+		if chain.PruningEnabled && checkpoint.ToHeight < retained.From {
+			logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Height: checkpoint.FromHeight, Success: true, Message: fmt.Sprintf("Skipping backfill of %d-%d, it is older than the signing window", checkpoint.FromHeight, checkpoint.ToHeight)})
+			checkpoint.NextHeight = checkpoint.ToHeight + 1
+			if err := db.SaveBackfillCheckpoint(checkpoint); err != nil {
+				return err
+			}
+			continue
+		}
+		if checkpoint.NextHeight < earliest {
+			logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Height: checkpoint.NextHeight, Success: false, Message: fmt.Sprintf("Pending backfill of %d-%d is below the earliest height %d of the node, run the backfill command with --pending against an archive node", checkpoint.FromHeight, checkpoint.ToHeight, earliest)})
+			continue
+		}
+		logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Height: checkpoint.NextHeight, Success: true, Message: fmt.Sprintf("Running pending backfill of %d-%d", checkpoint.FromHeight, checkpoint.ToHeight)})
+		if _, err := Backfill(ctx, chain, db, checkpoint.FromHeight, checkpoint.ToHeight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
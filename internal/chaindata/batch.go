@@ -0,0 +1,105 @@
+package chaindata
+
+import (
+	"fmt"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// Defaults for batch_size and batch_timeout
+const (
+	DefaultBatchSize    = 100
+	DefaultBatchTimeout = time.Second
+)
+
+// blockBatch writes consecutive heights in one transaction, committed once it holds Chain.BatchSize heights or has been
+// open for Chain.BatchTimeout. A height only counts as stored once its transaction committed, a failed write rolls
+// back every height of the open batch.
+type blockBatch struct {
+	chain Chain
+	db    db_utils.Store
+
+	tx       db_utils.Tx
+	heights  int
+	openedAt time.Time
+	// highest height in the open transaction
+	pendingHeight int
+	// highest height of the last committed transaction
+	committedHeight int
+
+	// beforeCommit runs in the transaction right before it commits, e.g. to move a checkpoint along with the heights
+	beforeCommit func(tx db_utils.BlockWriter, height int) error
+}
+
+// newBlockBatch starts a batch of the heights after committedHeight
+func newBlockBatch(chain Chain, db db_utils.Store, committedHeight int) *blockBatch {
+	return &blockBatch{chain: chain, db: db, committedHeight: committedHeight}
+}
+
+// store adds the block of height to the batch, recordIncidents is false for heights stored out of order
+func (b *blockBatch) store(height int, block api.Block, recordIncidents bool) error {
+	if b.tx == nil {
+		tx, err := b.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin block transaction: %v", err)
+		}
+		b.tx = tx
+		b.openedAt = time.Now()
+	}
+
+	if err := storeBlockRecords(b.chain, b.tx, height, block, recordIncidents); err != nil {
+		b.rollback()
+		return err
+	}
+	b.heights++
+	b.pendingHeight = height
+
+	if b.heights >= b.chain.BatchSize || time.Since(b.openedAt) >= b.chain.BatchTimeout {
+		return b.commit()
+	}
+	return nil
+}
+
+// commit commits the open transaction, if any
+func (b *blockBatch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	if b.beforeCommit != nil {
+		if err := b.beforeCommit(b.tx, b.pendingHeight); err != nil {
+			b.rollback()
+			return err
+		}
+	}
+
+	start := time.Now()
+	if err := b.tx.Commit(); err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: b.chain.ChainID, Operation: "CommitBatch", Height: b.pendingHeight, Success: false, Message: err.Error()})
+		b.rollback()
+		return fmt.Errorf("failed to commit heights %d-%d: %v", b.committedHeight+1, b.pendingHeight, err)
+	}
+	api.BatchCommitLatency.WithLabelValues(b.chain.ChainID).Observe(time.Since(start).Seconds())
+	api.BatchHeights.WithLabelValues(b.chain.ChainID).Observe(float64(b.heights))
+
+	b.committedHeight = b.pendingHeight
+	b.tx = nil
+	b.heights = 0
+	return nil
+}
+
+// rollback discards the open transaction. The in-memory incident and validator set state may describe the
+// discarded heights, it is read from the DB again.
+func (b *blockBatch) rollback() {
+	if b.tx == nil {
+		return
+	}
+	b.tx.Rollback()
+	b.tx = nil
+	b.heights = 0
+	b.pendingHeight = b.committedHeight
+	b.chain.Incidents.reset()
+	b.chain.ValidatorSets.resetLastSnapshot()
+}
@@ -1,68 +1,355 @@
 package chaindata
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
 	"time"
 
 	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/config_utils"
 	"cometbftsignrate/internal/db_utils"
 	"cometbftsignrate/internal/logger"
+	"cometbftsignrate/internal/supervisor"
 )
 
+const (
+	IngestModePoll      = "poll"
+	IngestModeWebsocket = "websocket"
+)
+
+// Validator is one validator address tracked on a chain
+type Validator struct {
+	Address  string
+	Name     string
+	Verifier *SignatureVerifier
+}
+
 type Chain struct {
 	ChainID        string
 	HostAddress    string
-	HexAddress     string
+	Validators     []Validator
 	RPCdelay       string
 	SigningWindow  int
 	PruningEnabled bool
+	IngestMode     string
+	Concurrency    int
+	Hosts          []string
+	Pool           *api.HostPool
+	// TrackValidatorSet stores the vote of every validator in each commit, not only the tracked ones
+	TrackValidatorSet bool
+	ValidatorSets     *validatorSetCache
+	Incidents         *incidentTracker
+	// NetworkMissThreshold is the share of the rest of the network that has to miss a block for our miss to be network-wide
+	NetworkMissThreshold float64
+	Gaps                 *gapScanner
+	Health               *nodeHealthTracker
+	// heights written per transaction, and how long a transaction may stay open before it is committed
+	BatchSize    int
+	BatchTimeout time.Duration
 }
 
-func ProcessChain(chain Chain, db *sql.DB, initialScan int, sleepDuration int) {
-	for {
-		// Get current height from RPC (also checks if chainID in config file matches the nodes chainID)
-		currentHeight, err := api.GetCurrentHeight(chain.ChainID, chain.HostAddress)
-		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "ProcessChain", Success: false, Message: err.Error()})
-			os.Exit(1)
+// NewChain builds the runtime chain from its config, `host` and `hosts` are merged into one pool of RPC endpoints
+func NewChain(config config_utils.ChainConfig) (Chain, error) {
+	var hosts []string
+	if config.HostAddress != "" {
+		hosts = append(hosts, config.HostAddress)
+	}
+	for _, host := range config.Hosts {
+		if host != config.HostAddress {
+			hosts = append(hosts, host)
 		}
-		logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chain.ChainID, Height: currentHeight, Operation: "getCurrentHeight", Success: true})
+	}
+
+	chain := Chain{
+		ChainID:        config.ChainID,
+		HostAddress:    config.HostAddress,
+		RPCdelay:       config.RPCdelay,
+		SigningWindow:  config.SigningWindow,
+		PruningEnabled: config.PruningEnabled,
+		IngestMode:     config.IngestMode,
+		Concurrency:    config.Concurrency,
+		Hosts:          hosts,
+		Pool:           api.NewHostPool(config.ChainID, hosts),
+
+		TrackValidatorSet:    config.TrackValidatorSet,
+		NetworkMissThreshold: config.NetworkMissThreshold,
+	}
+	chain.Pool.SetRateLimit(config.RateLimit)
+	if chain.NetworkMissThreshold <= 0 {
+		chain.NetworkMissThreshold = DefaultNetworkMissThreshold
+	}
+	chain.ValidatorSets = newValidatorSetCache(config.ChainID, chain.Pool)
+	chain.Incidents = newIncidentTracker()
 
-		// Get last checked height from DB
-		// if no record exists, use current height less initialScan
-		// if pruning is enabled, and latest record is older than (current_height - signing_window) use the current height less the signing window
-		lastCheckedHeight, err := db_utils.GetLastBlockHeight(db, chain.ChainID, currentHeight, chain.SigningWindow, chain.PruningEnabled)
+	chain.Gaps = &gapScanner{interval: DefaultGapScanInterval}
+	if config.GapScanInterval != "" {
+		interval, err := time.ParseDuration(config.GapScanInterval)
 		if err != nil {
-			logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetLastBlockHeight", Success: false})
-			logger.PostLog("WARN", "Falling back to using current height less initialScan || signing window")
+			return Chain{}, fmt.Errorf("chain %s: invalid gap_scan_interval %q: %v", config.ChainID, config.GapScanInterval, err)
 		}
+		chain.Gaps.interval = interval
+	}
 
-		if lastCheckedHeight == 0 {
-			logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetLastBlockHeight", Success: false, Message: fmt.Sprintf("Using current height less %d", initialScan)})
-			lastCheckedHeight = currentHeight - initialScan
+	chain.Health = newNodeHealthTracker(DefaultMaxNodeLag, DefaultStallTimeout)
+	if config.MaxNodeLag != "" {
+		maxLag, err := time.ParseDuration(config.MaxNodeLag)
+		if err != nil {
+			return Chain{}, fmt.Errorf("chain %s: invalid max_node_lag %q: %v", config.ChainID, config.MaxNodeLag, err)
+		}
+		chain.Health.maxLag = maxLag
+	}
+	if config.StallTimeout != "" {
+		stallTimeout, err := time.ParseDuration(config.StallTimeout)
+		if err != nil {
+			return Chain{}, fmt.Errorf("chain %s: invalid stall_timeout %q: %v", config.ChainID, config.StallTimeout, err)
 		}
-		logger.PostLog("INFO", fmt.Sprintf("Chain %s will start syncing from height %d", chain.ChainID, lastCheckedHeight))
+		chain.Health.stallTimeout = stallTimeout
+	}
 
-		// Insert data for all blocks between last checked height and current height
-		for i := lastCheckedHeight; i < currentHeight; i++ {
-			timestamp, sigFound, valTimestamp, signature, proposerMatch, numTXs, emptyBlock := api.CheckBlockSignature(chain.ChainID, chain.HostAddress, chain.HexAddress, i, chain.RPCdelay)
+	chain.BatchSize, chain.BatchTimeout = DefaultBatchSize, DefaultBatchTimeout
+	if config.BatchSize > 0 {
+		chain.BatchSize = config.BatchSize
+	}
+	if config.BatchTimeout != "" {
+		batchTimeout, err := time.ParseDuration(config.BatchTimeout)
+		if err != nil {
+			return Chain{}, fmt.Errorf("chain %s: invalid batch_timeout %q: %v", config.ChainID, config.BatchTimeout, err)
+		}
+		chain.BatchTimeout = batchTimeout
+	}
 
-			err := db_utils.InsertBlockHeight(db, timestamp, chain.ChainID, chain.HexAddress, i, sigFound, valTimestamp, signature, proposerMatch, numTXs, emptyBlock)
+	for _, validatorConfig := range config.Validators() {
+		validator := Validator{Address: validatorConfig.Address, Name: validatorConfig.Name}
+		if config.VerifySignatures {
+			verifier, err := NewSignatureVerifier(config.ChainID, validatorConfig.Address, validatorConfig.PubKey, chain.Pool)
 			if err != nil {
-				logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "InsertBlockHeight", Height: i, SignatureFound: sigFound, Success: false, Message: err.Error()})
-				os.Exit(1)
+				return Chain{}, fmt.Errorf("chain %s: %v", config.ChainID, err)
 			}
+			validator.Verifier = verifier
 		}
-		logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "InsertBlockHeight", Success: true, Message: fmt.Sprintf("Finished processing signatures, sleeping for %d seconds", sleepDuration)})
+		chain.Validators = append(chain.Validators, validator)
+	}
+	if len(chain.Validators) == 0 {
+		return Chain{}, fmt.Errorf("chain %s: no validator address configured", config.ChainID)
+	}
+
+	return chain, nil
+}
 
-		// Prune old records if pruning is enabled - delete records older than the signing window
-		if chain.PruningEnabled {
-			logger.PostLog("INFO", logger.ModulePruner{ChainID: chain.ChainID, Operation: "PruneOldRecords", Height: currentHeight, Message: fmt.Sprintf("Pruning block data older than %d blocks", chain.SigningWindow)})
-			db_utils.DeleteOldRecords(db, chain.ChainID, chain.SigningWindow)
+// ProcessChain keeps the chains signatures up to date until ctx is cancelled.
+// It only returns an error if the chain can not be processed without a config change.
+func ProcessChain(ctx context.Context, chain Chain, db db_utils.Store, initialScan int, sleepDuration int) error {
+	if chain.IngestMode == IngestModeWebsocket {
+		return processChainWebsocket(ctx, chain, db, initialScan, sleepDuration)
+	}
+
+	for {
+		_, err := catchUp(ctx, chain, db, initialScan)
+		if api.IsFatal(err) {
+			return err
 		}
+		if err != nil {
+			supervisor.SetDegraded(ctx, err)
+		} else {
+			supervisor.SetHealthy(ctx)
+			logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "InsertBlockHeight", Success: true, Message: fmt.Sprintf("Finished processing signatures, sleeping for %d seconds", sleepDuration)})
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(sleepDuration) * time.Second):
+		}
+	}
+}
+
+// catchUp polls the node for its current height and stores every block between the last checked height and it.
+// Returns the last height that was stored.
+func catchUp(ctx context.Context, chain Chain, db db_utils.Store, initialScan int) (int, error) {
+	// Refresh the health of every RPC endpoint before picking one
+	chain.Pool.Probe()
+
+	// Get current height from RPC (also checks if chainID in config file matches the nodes chainID)
+	var status api.ChainStatus
+	err := api.Retry(ctx, api.DefaultBackoff, func() error {
+		var err error
+		status, err = api.GetChainStatus(chain.ChainID, chain.Pool)
+		return err
+	})
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "ProcessChain", Success: false, Message: err.Error()})
+		return 0, err
+	}
+	currentHeight := status.LatestHeight
+	logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chain.ChainID, Height: currentHeight, Operation: "getCurrentHeight", Success: true})
+
+	// a syncing or stuck node serves old blocks as if they were the newest, wait for it rather than store them
+	if err := checkNodeHealth(chain, status); err != nil {
+		return 0, err
+	}
 
-		time.Sleep(time.Duration(sleepDuration) * time.Second)
+	// Get last checked height from DB
+	// if no record exists, use current height less initialScan
+	// if pruning is enabled, and latest record is older than (current_height - signing_window) use the current height less the signing window
+	lastCheckedHeight, err := db.GetLastBlockHeight(chain.ChainID, currentHeight, chain.SigningWindow, chain.PruningEnabled)
+	if err != nil {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetLastBlockHeight", Success: false})
+		logger.PostLog("WARN", "Falling back to using current height less initialScan || signing window")
+	}
+
+	if lastCheckedHeight == 0 {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "GetLastBlockHeight", Success: false, Message: fmt.Sprintf("Using current height less %d", initialScan)})
+		lastCheckedHeight = currentHeight - initialScan
+	}
+
+	// initial_scan or a stale DB can point below the history the node retains
+	if status.EarliestHeight > 0 && lastCheckedHeight < status.EarliestHeight {
+		gapStart := lastCheckedHeight
+		if _, latestStored, err := db.GetStoredHeightRange(chain.ChainID); err == nil && latestStored == lastCheckedHeight {
+			gapStart++
+		}
+		if lastCheckedHeight, err = clampToEarliest(chain, db, status, gapStart, currentHeight); err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "InsertKnownGap", Success: false, Message: err.Error()})
+			return 0, err
+		}
+	}
+	logger.PostLog("INFO", fmt.Sprintf("Chain %s will start syncing from height %d", chain.ChainID, lastCheckedHeight))
+
+	// Insert data for all blocks between last checked height and current height
+	lastStoredHeight, err := syncRange(ctx, chain, db, lastCheckedHeight, currentHeight)
+
+	// Prune old records if pruning is enabled - delete records older than the signing window
+	if chain.PruningEnabled {
+		logger.PostLog("INFO", logger.ModulePruner{ChainID: chain.ChainID, Operation: "PruneOldRecords", Height: currentHeight, Message: fmt.Sprintf("Pruning block data older than %d blocks", chain.SigningWindow)})
+		pruneChain(chain, db)
+	}
+
+	// Re-fetch heights missing below the newest stored one
+	scanGaps(ctx, chain, db)
+
+	return lastStoredHeight, err
+}
+
+// syncRange fetches the blocks in [from, to) using the chains configured concurrency and stores them in height order.
+// Transient RPC errors are retried with backoff, any other error stops the range at the failing height.
+// Returns the last height that was committed.
+func syncRange(ctx context.Context, chain Chain, db db_utils.Store, from int, to int) (int, error) {
+	fetch := blockFetcher(chain)
+	batch := newBlockBatch(chain, db, from-1)
+	store := func(height int, block api.Block) error {
+		return batch.store(height, block, true)
+	}
+
+	_, err := fetchRange(ctx, from, to, chain.Concurrency, fetch, store)
+	// the heights fetched before an error are still valid, only committed ones count as stored
+	if commitErr := batch.commit(); commitErr != nil && err == nil {
+		err = commitErr
+	}
+	lastStoredHeight := batch.committedHeight
+	if err != nil && ctx.Err() == nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "syncRange", Height: lastStoredHeight, Success: false, Message: err.Error()})
+		return lastStoredHeight, err
+	}
+	return lastStoredHeight, nil
+}
+
+// pruneChain deletes every record of the chain older than its signing window
+func pruneChain(chain Chain, db db_utils.Store) {
+	if err := db.DeleteOldRecords(chain.ChainID, chain.SigningWindow); err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "DeleteOldRecords", Success: false, Message: err.Error()})
+	}
+	if err := db.DeleteOldValidatorSets(chain.ChainID, chain.SigningWindow); err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "DeleteOldValidatorSets", Success: false, Message: err.Error()})
+	}
+	if err := db.DeleteOldKnownGaps(chain.ChainID, chain.SigningWindow); err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "DeleteOldKnownGaps", Success: false, Message: err.Error()})
+	}
+	if chain.TrackValidatorSet {
+		if err := db.DeleteOldParticipation(chain.ChainID, chain.SigningWindow); err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "DeleteOldParticipation", Success: false, Message: err.Error()})
+		}
+	}
+}
+
+// storeBlockRecords writes one row per tracked validator for the block within the transaction db of its batch,
+// recordIncidents is false for heights stored out of order
+func storeBlockRecords(chain Chain, db db_utils.BlockWriter, height int, block api.Block, recordIncidents bool) error {
+	chain.ValidatorSets.observe(height, block.Header.ValidatorsHash)
+	if err := snapshotValidatorSet(chain, db, height, block); err != nil {
+		return err
+	}
+
+	// the validator set of the commit height tells a missed signature apart from a validator that was not in the set
+	commitHeight := api.CommitHeight(block, height)
+	validatorSet, err := chain.ValidatorSets.get(commitHeight)
+	if err != nil {
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "getValidators", Height: commitHeight, Success: false, Message: "Validator set unavailable, assuming the tracked validators are in the active set"})
+	}
+
+	if chain.TrackValidatorSet {
+		if err := storeParticipation(chain, db, height, block, validatorSet); err != nil {
+			return err
+		}
+	}
+
+	participation := newBlockParticipation(block, validatorSet)
+	powerFraction, powerKnown := participation.powerFraction()
+
+	for _, validator := range chain.Validators {
+		signature := api.ParseBlockSignature(chain.ChainID, block, validator.Address, height)
+
+		inActiveSet, votingPower := true, int64(0)
+		if validatorSet != nil {
+			member, ok := api.FindValidator(validatorSet, validator.Address)
+			inActiveSet, votingPower = ok, member.Power()
+		}
+
+		if validator.Verifier != nil {
+			signature.VerificationStatus = validator.Verifier.Verify(signature)
+			if signature.VerificationStatus == VerificationInvalid {
+				// a signature that does not verify is not proof the validator signed
+				signature.SignatureFound = false
+				api.SignatureVerificationFailures.WithLabelValues(chain.ChainID, validator.Address, validator.Name).Inc()
+			}
+		}
+
+		err := db.InsertBlockHeight(db_utils.BlockRecord{
+			Timestamp:          signature.Timestamp,
+			ChainID:            chain.ChainID,
+			Address:            validator.Address,
+			BlockHeight:        height,
+			CommitHeight:       signature.CommitHeight,
+			SignatureFound:     signature.SignatureFound,
+			BlockIDFlag:        signature.BlockIDFlag,
+			ValidatorTimestamp: signature.ValidatorTimestamp,
+			Signature:          signature.Signature,
+			ProposerMatch:      signature.ProposerMatch,
+			NumTXs:             signature.NumTXs,
+			EmptyBlock:         signature.EmptyBlock,
+			VerificationStatus: signature.VerificationStatus,
+			InActiveSet:        inActiveSet,
+			VotingPower:        votingPower,
+			IsolatedMiss:       inActiveSet && !signature.SignatureFound && participation.isolated(validator.Address, chain.NetworkMissThreshold),
+
+			NetworkCommittedFraction:      participation.validatorFraction(),
+			NetworkCommittedPowerFraction: sql.NullFloat64{Float64: powerFraction, Valid: powerKnown},
+		})
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "InsertBlockHeight", Height: height, SignatureFound: signature.SignatureFound, Success: false, Message: err.Error()})
+			return err
+		}
+
+		if !recordIncidents {
+			continue
+		}
+
+		// Group consecutive misses into incidents
+		err = chain.Incidents.record(db, chain.ChainID, validator.Address, signature.CommitHeight, signature.Timestamp, !signature.SignatureFound, inActiveSet)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "RecordIncident", Height: height, Success: false, Message: err.Error()})
+			return err
+		}
 	}
+	return nil
 }
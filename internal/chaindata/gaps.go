@@ -0,0 +1,189 @@
+package chaindata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// DefaultGapScanInterval is how often the stored heights are scanned for gaps when gap_scan_interval is not set
+const DefaultGapScanInterval = 10 * time.Minute
+
+// gapScanner remembers when the chain was last scanned for gaps
+type gapScanner struct {
+	mu       sync.Mutex
+	interval time.Duration
+	lastScan time.Time
+}
+
+// due reports whether the next scan should run and if so marks it as started
+func (s *gapScanner) due() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 || time.Since(s.lastScan) < s.interval {
+		return false
+	}
+	s.lastScan = time.Now()
+	return true
+}
+
+// retainedRange returns the heights the DB should hold for the chain: from the oldest stored height, or the start of the
+// signing window if pruning removes older ones, up to the newest stored height. False if nothing is stored.
+func retainedRange(chain Chain, db db_utils.Store) (db_utils.HeightRange, bool, error) {
+	minHeight, maxHeight, err := db.GetStoredHeightRange(chain.ChainID)
+	if err != nil || maxHeight == 0 {
+		return db_utils.HeightRange{}, false, err
+	}
+	retained := db_utils.HeightRange{From: minHeight, To: maxHeight}
+	if chain.PruningEnabled && maxHeight-chain.SigningWindow+1 > retained.From {
+		retained.From = maxHeight - chain.SigningWindow + 1
+	}
+	return retained, true, nil
+}
+
+// FindGaps returns the heights missing for each tracked validator within the retained range of the chain
+func FindGaps(chain Chain, db db_utils.Store) (map[string][]db_utils.HeightRange, error) {
+	gaps := make(map[string][]db_utils.HeightRange, len(chain.Validators))
+	retained, ok, err := retainedRange(chain, db)
+	if err != nil || !ok {
+		return gaps, err
+	}
+
+	// heights the node could not serve are already accounted for
+	known, err := db.GetKnownGaps(chain.ChainID, retained.From, retained.To)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, validator := range chain.Validators {
+		missing, err := db.GetMissingHeights(chain.ChainID, validator.Address, retained.From, retained.To)
+		if err != nil {
+			return nil, err
+		}
+		gaps[validator.Address] = subtractKnownGaps(missing, known)
+	}
+	return gaps, nil
+}
+
+// mergeGaps combines the missing heights of every validator into ascending, non-overlapping ranges
+func mergeGaps(gaps map[string][]db_utils.HeightRange) []db_utils.HeightRange {
+	var ranges []db_utils.HeightRange
+	for _, missing := range gaps {
+		ranges = append(ranges, missing...)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+
+	var merged []db_utils.HeightRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.From <= merged[n-1].To+1 {
+			if r.To > merged[n-1].To {
+				merged[n-1].To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// RepairGaps fetches and stores every height in gaps again. The rows of every tracked validator at a repaired height
+// are upserted, rows that already exist are overwritten with the refetched block, including their active set and
+// isolated miss classification from the validator set known now. Repaired heights are not grouped into incidents,
+// the streak around them was already decided when the newer heights were stored.
+// Heights below the nodes earliest_block_height are recorded as a known gap, any other range the node can not serve
+// is skipped. Returns the number of heights stored.
+func RepairGaps(ctx context.Context, chain Chain, db db_utils.Store, gaps map[string][]db_utils.HeightRange) (int, error) {
+	fetch := blockFetcher(chain)
+	status, err := api.GetChainStatus(chain.ChainID, chain.Pool)
+	if err != nil {
+		return 0, err
+	}
+
+	repaired := 0
+	for _, gap := range mergeGaps(gaps) {
+		// heights the node has pruned since are recorded as known gaps instead
+		from, err := clampToEarliest(chain, db, status, gap.From, gap.To)
+		if err != nil {
+			return repaired, err
+		}
+		if from > gap.To {
+			continue
+		}
+
+		batch := newBlockBatch(chain, db, from-1)
+		store := func(height int, block api.Block) error {
+			return batch.store(height, block, false)
+		}
+		_, err = fetchRange(ctx, from, gap.To+1, chain.Concurrency, fetch, store)
+		if commitErr := batch.commit(); commitErr != nil && err == nil {
+			err = commitErr
+		}
+		lastStored := batch.committedHeight
+		repaired += lastStored - from + 1
+		if ctx.Err() != nil {
+			return repaired, ctx.Err()
+		}
+		if api.IsFatal(err) {
+			return repaired, err
+		}
+		if err != nil {
+			logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "RepairGaps", Height: lastStored + 1, Success: false, Message: fmt.Sprintf("Could not repair heights %d-%d: %v", lastStored+1, gap.To, err)})
+		}
+	}
+	return repaired, nil
+}
+
+// scanGaps looks for missing heights once the scan interval has passed, repairs them and updates the gap metric
+func scanGaps(ctx context.Context, chain Chain, db db_utils.Store) {
+	if chain.Gaps == nil || !chain.Gaps.due() {
+		return
+	}
+
+	gaps, err := FindGaps(chain, db)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "FindGaps", Success: false, Message: err.Error()})
+		return
+	}
+	if missing := countMissing(gaps); missing > 0 {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: chain.ChainID, Operation: "FindGaps", Success: true, Message: fmt.Sprintf("Found %d missing heights, repairing", missing)})
+		repaired, err := RepairGaps(ctx, chain, db, gaps)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "RepairGaps", Success: false, Message: err.Error()})
+		} else {
+			logger.PostLog("INFO", logger.ModuleDB{ChainID: chain.ChainID, Operation: "RepairGaps", Success: true, Message: fmt.Sprintf("Repaired %d heights", repaired)})
+		}
+
+		if gaps, err = FindGaps(chain, db); err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "FindGaps", Success: false, Message: err.Error()})
+			return
+		}
+	}
+
+	// backfills scheduled by a schema migration, or interrupted backfill commands, run at the same pace as the scans
+	if err := RunPendingBackfills(ctx, chain, db, true); err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "Backfill", Success: false, Message: err.Error()})
+	}
+
+	for _, validator := range chain.Validators {
+		missing := 0
+		for _, gap := range gaps[validator.Address] {
+			missing += gap.Count()
+		}
+		api.MissingHeights.WithLabelValues(chain.ChainID, validator.Address, validator.Name).Set(float64(missing))
+	}
+}
+
+// countMissing returns the number of heights missing for at least one validator
+func countMissing(gaps map[string][]db_utils.HeightRange) int {
+	missing := 0
+	for _, gap := range mergeGaps(gaps) {
+		missing += gap.Count()
+	}
+	return missing
+}
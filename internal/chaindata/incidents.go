@@ -0,0 +1,109 @@
+package chaindata
+
+import (
+	"fmt"
+	"sync"
+
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// incidentTracker groups consecutive missed commit heights of each validator into incidents.
+// The open incident of every validator is kept in memory and loaded from the DB on first use.
+type incidentTracker struct {
+	mu     sync.Mutex
+	loaded map[string]bool
+	open   map[string]*db_utils.Incident
+}
+
+func newIncidentTracker() *incidentTracker {
+	return &incidentTracker{
+		loaded: make(map[string]bool),
+		open:   make(map[string]*db_utils.Incident),
+	}
+}
+
+// reset forgets the open incidents, they are loaded from the DB again after a batch of blocks was rolled back
+func (t *incidentTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loaded = make(map[string]bool)
+	t.open = make(map[string]*db_utils.Incident)
+}
+
+// record updates the incidents of address with the outcome of commitHeight.
+// Heights where the validator was not in the active set neither extend nor resolve an incident.
+func (t *incidentTracker) record(db db_utils.BlockWriter, chainID string, address string, commitHeight int, timestamp string, missed bool, inActiveSet bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded[address] {
+		incident, ok, err := db.GetOpenIncident(chainID, address)
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.open[address] = &incident
+		}
+		t.loaded[address] = true
+	}
+
+	if !inActiveSet {
+		return nil
+	}
+
+	open := t.open[address]
+	if open != nil && commitHeight <= open.EndHeight {
+		// already part of the incident
+		return nil
+	}
+
+	if !missed {
+		if open == nil {
+			return nil
+		}
+		open.Status = db_utils.IncidentResolved
+		open.ResolvedHeight = commitHeight
+		if err := db.UpdateIncident(*open); err != nil {
+			return err
+		}
+		logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "ResolveIncident", Height: commitHeight, Success: true, Message: fmt.Sprintf("%s signed again after missing %d consecutive blocks (%d-%d)", address, open.BlockCount, open.StartHeight, open.EndHeight)})
+		delete(t.open, address)
+		return nil
+	}
+
+	if open != nil && commitHeight == open.EndHeight+1 {
+		open.EndHeight = commitHeight
+		open.EndTime = timestamp
+		open.BlockCount++
+		return db.UpdateIncident(*open)
+	}
+
+	// a height between the open incident and this miss was never stored, the streak can not be proven to continue
+	if open != nil {
+		open.Status = db_utils.IncidentResolved
+		open.ResolvedHeight = open.EndHeight + 1
+		if err := db.UpdateIncident(*open); err != nil {
+			return err
+		}
+	}
+
+	incident := db_utils.Incident{
+		ChainID:     chainID,
+		Address:     address,
+		StartHeight: commitHeight,
+		EndHeight:   commitHeight,
+		StartTime:   timestamp,
+		EndTime:     timestamp,
+		BlockCount:  1,
+		Status:      db_utils.IncidentOpen,
+	}
+	id, err := db.InsertIncident(incident)
+	if err != nil {
+		return err
+	}
+	incident.ID = id
+	t.open[address] = &incident
+	logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "OpenIncident", Height: commitHeight, Success: true, Message: fmt.Sprintf("%s missed a block, incident opened", address)})
+	return nil
+}
@@ -0,0 +1,56 @@
+package chaindata
+
+import (
+	"fmt"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// reason stored with heights below the nodes earliest_block_height
+const gapReasonPruned = "pruned by node"
+
+// clampToEarliest returns the first height of [from, to] the node of status can still serve.
+// The heights below it are recorded as a known gap so they are neither counted as missed nor as signed.
+func clampToEarliest(chain Chain, db db_utils.Store, status api.ChainStatus, from int, to int) (int, error) {
+	if status.EarliestHeight == 0 || from >= status.EarliestHeight {
+		return from, nil
+	}
+
+	gapEnd := status.EarliestHeight - 1
+	if gapEnd > to {
+		gapEnd = to
+	}
+	if err := db.InsertKnownGap(chain.ChainID, from, gapEnd, gapReasonPruned); err != nil {
+		return from, err
+	}
+	logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "clampToEarliest", Height: from, Success: true, Message: fmt.Sprintf("Heights %d-%d are pruned on %s (earliest_block_height %d), recorded as a known gap", from, gapEnd, status.Host, status.EarliestHeight)})
+	return gapEnd + 1, nil
+}
+
+// subtractKnownGaps removes the heights of known gaps from the ascending ranges of missing heights
+func subtractKnownGaps(missing []db_utils.HeightRange, known []db_utils.KnownGap) []db_utils.HeightRange {
+	var remaining []db_utils.HeightRange
+	for _, r := range missing {
+		pieces := []db_utils.HeightRange{r}
+		for _, gap := range known {
+			var next []db_utils.HeightRange
+			for _, piece := range pieces {
+				if gap.ToHeight < piece.From || gap.FromHeight > piece.To {
+					next = append(next, piece)
+					continue
+				}
+				if gap.FromHeight > piece.From {
+					next = append(next, db_utils.HeightRange{From: piece.From, To: gap.FromHeight - 1})
+				}
+				if gap.ToHeight < piece.To {
+					next = append(next, db_utils.HeightRange{From: gap.ToHeight + 1, To: piece.To})
+				}
+			}
+			pieces = next
+		}
+		remaining = append(remaining, pieces...)
+	}
+	return remaining
+}
@@ -0,0 +1,84 @@
+package chaindata
+
+import (
+	"cometbftsignrate/internal/api"
+)
+
+// DefaultNetworkMissThreshold is the share of the rest of the network that has to miss a block as well for our miss to count as network-wide
+const DefaultNetworkMissThreshold = 0.1
+
+// blockParticipation is the part of the validator set that committed a block
+type blockParticipation struct {
+	slots          int
+	committed      int
+	totalPower     int64
+	committedPower int64
+	// voting power by address, nil if the validator set of the commit height is unknown
+	power     map[string]int64
+	committer map[string]bool
+}
+
+func newBlockParticipation(block api.Block, validatorSet []api.Validator) blockParticipation {
+	participation := blockParticipation{slots: len(block.LastCommit.Signatures), committer: make(map[string]bool)}
+
+	if validatorSet != nil {
+		participation.power = make(map[string]int64, len(validatorSet))
+		for _, validator := range validatorSet {
+			participation.power[validator.Address] = validator.Power()
+			participation.totalPower += validator.Power()
+		}
+	}
+
+	for _, sig := range block.LastCommit.Signatures {
+		if sig.Flag() != api.BlockIDFlagCommit {
+			continue
+		}
+		participation.committed++
+		participation.committedPower += participation.power[sig.ValidatorAddress]
+		participation.committer[sig.ValidatorAddress] = true
+	}
+	return participation
+}
+
+// validatorFraction returns the share of validators that committed the block
+func (p blockParticipation) validatorFraction() float64 {
+	if p.slots == 0 {
+		return 0
+	}
+	return float64(p.committed) / float64(p.slots)
+}
+
+// powerFraction returns the share of voting power that committed the block, false if the validator set is unknown
+func (p blockParticipation) powerFraction() (float64, bool) {
+	if p.power == nil || p.totalPower == 0 {
+		return 0, false
+	}
+	return float64(p.committedPower) / float64(p.totalPower), true
+}
+
+// isolated reports whether a miss by address happened while no more than threshold of the rest of the network missed as well.
+// The rest of the network is weighted by voting power when the validator set is known.
+// A commit by address itself (e.g. one whose signature failed verification) is not counted for the rest of the network.
+func (p blockParticipation) isolated(address string, threshold float64) bool {
+	if p.power != nil && p.totalPower > 0 {
+		othersPower := p.totalPower - p.power[address]
+		othersCommitted := p.committedPower
+		if p.committer[address] {
+			othersCommitted -= p.power[address]
+		}
+		if othersPower <= 0 {
+			return true
+		}
+		return float64(othersPower-othersCommitted)/float64(othersPower) <= threshold
+	}
+
+	others := p.slots - 1
+	othersCommitted := p.committed
+	if p.committer[address] {
+		othersCommitted--
+	}
+	if others <= 0 {
+		return true
+	}
+	return float64(others-othersCommitted)/float64(others) <= threshold
+}
@@ -0,0 +1,97 @@
+package chaindata
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/logger"
+)
+
+// Defaults for max_node_lag and stall_timeout
+const (
+	DefaultMaxNodeLag   = 2 * time.Minute
+	DefaultStallTimeout = 2 * time.Minute
+)
+
+// nodeHealthTracker decides from the /status of a chains node whether its blocks can be trusted to be current.
+// It remembers when the reported height last advanced, a node can be stuck without reporting catching_up.
+type nodeHealthTracker struct {
+	mu           sync.Mutex
+	maxLag       time.Duration
+	stallTimeout time.Duration
+	lastHeight   int
+	lastAdvance  time.Time
+	paused       bool
+}
+
+func newNodeHealthTracker(maxLag time.Duration, stallTimeout time.Duration) *nodeHealthTracker {
+	return &nodeHealthTracker{maxLag: maxLag, stallTimeout: stallTimeout}
+}
+
+// check evaluates status, a zero maxLag or stallTimeout disables that check
+func (t *nodeHealthTracker) check(chainID string, status api.ChainStatus) api.NodeHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.lastAdvance.IsZero() || status.LatestHeight > t.lastHeight {
+		t.lastHeight = status.LatestHeight
+		t.lastAdvance = now
+	}
+
+	health := api.NodeHealth{
+		ChainID:        chainID,
+		Host:           status.Host,
+		CatchingUp:     status.CatchingUp,
+		LatestHeight:   status.LatestHeight,
+		StalledSeconds: now.Sub(t.lastAdvance).Seconds(),
+		CheckedAt:      now.UTC().Format(time.RFC3339),
+	}
+	// older nodes do not report latest_block_time, only the stall check applies to them
+	var lag time.Duration
+	if !status.LatestBlockTime.IsZero() {
+		health.LatestBlockTime = status.LatestBlockTime.UTC().Format(time.RFC3339Nano)
+		if lag = now.Sub(status.LatestBlockTime); lag < 0 {
+			lag = 0
+		}
+		health.LagSeconds = lag.Seconds()
+	}
+
+	var reasons []string
+	if status.CatchingUp {
+		reasons = append(reasons, "node is catching up")
+	}
+	if t.maxLag > 0 && lag > t.maxLag {
+		reasons = append(reasons, fmt.Sprintf("latest block is %s old", lag.Round(time.Second)))
+	}
+	if stalled := now.Sub(t.lastAdvance); t.stallTimeout > 0 && stalled > t.stallTimeout {
+		reasons = append(reasons, fmt.Sprintf("height %d has not advanced for %s", t.lastHeight, stalled.Round(time.Second)))
+	}
+	health.Reason = strings.Join(reasons, ", ")
+	health.Healthy = len(reasons) == 0
+	return health
+}
+
+// checkNodeHealth publishes the health of the node that answered status and returns an error if
+// ingestion has to pause, storing blocks from a node that is behind would record them as current.
+func checkNodeHealth(chain Chain, status api.ChainStatus) error {
+	health := chain.Health.check(chain.ChainID, status)
+	api.SetNodeHealth(health)
+
+	chain.Health.mu.Lock()
+	wasPaused := chain.Health.paused
+	chain.Health.paused = !health.Healthy
+	chain.Health.mu.Unlock()
+
+	if !health.Healthy {
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "NodeHealth", Height: status.LatestHeight, Success: false, Message: fmt.Sprintf("Pausing ingestion, %s: %s", status.Host, health.Reason)})
+		return fmt.Errorf("node %s unhealthy: %s", status.Host, health.Reason)
+	}
+	if wasPaused {
+		logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "NodeHealth", Height: status.LatestHeight, Success: true, Message: fmt.Sprintf("Resuming ingestion, %s is synced", status.Host)})
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package chaindata
+
+import (
+	"fmt"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// storeParticipation writes the vote of every validator in the blocks last_commit.
+// Absent slots carry no address, they are attributed through validatorSet, the set of the commit height.
+func storeParticipation(chain Chain, db db_utils.BlockWriter, height int, block api.Block, validatorSet []api.Validator) error {
+	commitHeight := api.CommitHeight(block, height)
+	signatures := block.LastCommit.Signatures
+
+	// without the set the absent validators are unknown, the signed slots are still stored
+	validators := validatorSet
+	if hasAbsentSlot(signatures) && validators != nil && len(validators) != len(signatures) {
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "storeParticipation", Height: commitHeight, Success: false, Message: fmt.Sprintf("Validator set has %d validators but the commit has %d slots", len(validators), len(signatures))})
+		validators = nil
+	}
+
+	records := make([]db_utils.ParticipationRecord, 0, len(signatures))
+	for i, sig := range signatures {
+		address := sig.ValidatorAddress
+		if address == "" {
+			if validators == nil {
+				continue
+			}
+			address = validators[i].Address
+		}
+		records = append(records, db_utils.ParticipationRecord{
+			Address:     address,
+			BlockIDFlag: sig.Flag(),
+			Proposed:    address == block.Header.ProposerAddress,
+		})
+	}
+
+	return db.InsertParticipation(chain.ChainID, height, commitHeight, records)
+}
+
+func hasAbsentSlot(signatures []api.CommitSig) bool {
+	for _, sig := range signatures {
+		if sig.ValidatorAddress == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package chaindata
+
+import (
+	"context"
+	"sync"
+
+	"cometbftsignrate/internal/api"
+)
+
+// number of heights each worker may fetch ahead of the lowest height not yet stored
+const fetchAheadPerWorker = 4
+
+type fetchResult struct {
+	height int
+	block  api.Block
+	err    error
+}
+
+// fetchRange fetches the blocks in [from, to) with `workers` concurrent requests and hands them to store strictly in height order.
+// A height is only passed to store once every height below it has been stored, so the highest stored height never
+// jumps past a gap. Stops at the first height that could not be fetched or stored.
+// fetch gets the context of the range, which is cancelled as soon as the range stops so in flight retries give up.
+// Returns the last height handed to store (from-1 if none) and the error that stopped it.
+func fetchRange(ctx context.Context, from int, to int, workers int, fetch func(ctx context.Context, height int) (api.Block, error), store func(height int, block api.Block) error) (int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if to-from < workers {
+		workers = to - from
+	}
+	if workers <= 0 {
+		return from - 1, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// tokens bound how far the fetchers can run ahead of the writer
+	tokens := make(chan struct{}, workers*fetchAheadPerWorker)
+	for i := 0; i < cap(tokens); i++ {
+		tokens <- struct{}{}
+	}
+
+	heights := make(chan int)
+	results := make(chan fetchResult, workers)
+
+	go func() {
+		defer close(heights)
+		for h := from; h < to; h++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tokens:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case heights <- h:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range heights {
+				block, err := fetch(ctx, h)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- fetchResult{height: h, block: block, err: err}:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// reorder results and store them in height order
+	pending := make(map[int]fetchResult)
+	next := from
+	for result := range results {
+		pending[result.height] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if result.err != nil {
+				return next - 1, result.err
+			}
+			if err := store(next, result.block); err != nil {
+				return next - 1, err
+			}
+			next++
+			tokens <- struct{}{}
+		}
+	}
+
+	return next - 1, ctx.Err()
+}
+
+// blockFetcher returns a fetch function for fetchRange that retries transient RPC errors with backoff
+func blockFetcher(chain Chain) func(ctx context.Context, height int) (api.Block, error) {
+	return func(ctx context.Context, height int) (api.Block, error) {
+		var block api.Block
+		err := api.Retry(ctx, api.DefaultBackoff, func() error {
+			var err error
+			block, err = api.FetchBlock(ctx, chain.ChainID, chain.Pool, height, chain.RPCdelay)
+			return err
+		})
+		return block, err
+	}
+}
@@ -0,0 +1,171 @@
+package chaindata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+)
+
+// number of recent heights whose validators_hash is remembered
+const validatorHashHistory = 64
+
+// number of distinct validator sets kept before the cache is reset
+const maxCachedValidatorSets = 16
+
+// validatorSetCache keeps the validator sets of a chain keyed by validators_hash,
+// so /validators is only queried when the set changes
+type validatorSetCache struct {
+	chainID string
+	pool    *api.HostPool
+
+	mu     sync.Mutex
+	sets   map[string][]api.Validator
+	hashes map[int]string
+
+	// validators_hash and height of the last snapshot written to the DB
+	snapshotHash   string
+	snapshotHeight int
+}
+
+func newValidatorSetCache(chainID string, pool *api.HostPool) *validatorSetCache {
+	return &validatorSetCache{
+		chainID: chainID,
+		pool:    pool,
+		sets:    make(map[string][]api.Validator),
+		hashes:  make(map[int]string),
+	}
+}
+
+// observe records the validators_hash of the header at height
+func (c *validatorSetCache) observe(height int, hash string) {
+	if hash == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hashes[height] = hash
+	delete(c.hashes, height-validatorHashHistory)
+}
+
+// get returns the validator set at height in commit order
+func (c *validatorSetCache) get(height int) ([]api.Validator, error) {
+	c.mu.Lock()
+	hash := c.hashes[height]
+	if set, ok := c.sets[hash]; ok && hash != "" {
+		c.mu.Unlock()
+		return set, nil
+	}
+	c.mu.Unlock()
+
+	set, err := api.GetValidators(c.chainID, c.pool, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// without the header of height the set can not be keyed, it is fetched again next time
+	if hash != "" {
+		c.mu.Lock()
+		if len(c.sets) >= maxCachedValidatorSets {
+			c.sets = make(map[string][]api.Validator)
+		}
+		c.sets[hash] = set
+		c.mu.Unlock()
+	}
+	return set, nil
+}
+
+// lastSnapshot returns the hash of the last snapshot written below height, false if it is not known in memory
+func (c *validatorSetCache) lastSnapshot(height int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snapshotHeight == 0 || height <= c.snapshotHeight {
+		return "", false
+	}
+	return c.snapshotHash, true
+}
+
+// resetLastSnapshot forgets the last snapshot, it is read from the DB again after a batch of blocks was rolled back
+func (c *validatorSetCache) resetLastSnapshot() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshotHash, c.snapshotHeight = "", 0
+}
+
+func (c *validatorSetCache) setLastSnapshot(height int, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height > c.snapshotHeight {
+		c.snapshotHeight = height
+		c.snapshotHash = hash
+	}
+}
+
+// snapshotValidatorSet stores the validator set of height when the validators_hash in its header differs from the last snapshot
+func snapshotValidatorSet(chain Chain, db db_utils.BlockWriter, height int, block api.Block) error {
+	hash := block.Header.ValidatorsHash
+	if hash == "" {
+		return nil
+	}
+
+	previous, ok := chain.ValidatorSets.lastSnapshot(height)
+	if !ok {
+		var err error
+		previous, err = db.GetValidatorSetHashAt(chain.ChainID, height)
+		if err != nil {
+			return err
+		}
+	}
+	if previous == hash {
+		chain.ValidatorSets.setLastSnapshot(height, hash)
+		return nil
+	}
+
+	validators, err := chain.ValidatorSets.get(height)
+	if err != nil {
+		// the snapshot is retried with the next block as the hash still differs
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "snapshotValidatorSet", Height: height, Success: false, Message: err.Error()})
+		return nil
+	}
+
+	err = db.InsertValidatorSetSnapshot(chain.ChainID, height, block.Header.Time, hash, validatorSetMembers(validators))
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		logger.PostLog("INFO", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "snapshotValidatorSet", Height: height, Success: true, Message: fmt.Sprintf("Validator set changed from %s to %s", previous, hash)})
+	}
+	chain.ValidatorSets.setLastSnapshot(height, hash)
+	return nil
+}
+
+// validatorSetMembers ranks validators by voting power, ties are broken by address like CometBFT orders the set
+func validatorSetMembers(validators []api.Validator) []db_utils.ValidatorSetMember {
+	members := make([]db_utils.ValidatorSetMember, 0, len(validators))
+	for _, validator := range validators {
+		priority, _ := strconv.ParseInt(validator.ProposerPriority, 10, 64)
+		members = append(members, db_utils.ValidatorSetMember{
+			Address:          validator.Address,
+			PubKeyType:       validator.PubKey.Type,
+			PubKey:           validator.PubKey.Value,
+			VotingPower:      validator.Power(),
+			ProposerPriority: priority,
+		})
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].VotingPower != members[j].VotingPower {
+			return members[i].VotingPower > members[j].VotingPower
+		}
+		return members[i].Address < members[j].Address
+	})
+	for i := range members {
+		members[i].Rank = i + 1
+	}
+	return members
+}
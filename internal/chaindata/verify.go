@@ -0,0 +1,215 @@
+package chaindata
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/logger"
+)
+
+// Verification status stored with every signature
+const (
+	VerificationSkipped      = 0
+	VerificationValid        = 1
+	VerificationInvalid      = 2
+	VerificationUnverifiable = 3
+)
+
+// SignedMsgType PRECOMMIT
+const precommitType = 2
+
+const ed25519PubKeyType = "tendermint/PubKeyEd25519"
+
+// SignatureVerifier checks the precommits of one validator against its ed25519 public key
+type SignatureVerifier struct {
+	chainID string
+	address string
+	pool    *api.HostPool
+
+	mu     sync.Mutex
+	pubKey ed25519.PublicKey
+}
+
+// NewSignatureVerifier creates a verifier for address. If pubKey (base64) is empty the key is looked up in /validators on first use.
+func NewSignatureVerifier(chainID string, address string, pubKey string, pool *api.HostPool) (*SignatureVerifier, error) {
+	verifier := &SignatureVerifier{chainID: chainID, address: address, pool: pool}
+	if pubKey == "" {
+		return verifier, nil
+	}
+
+	key, err := decodePubKey(pubKey, address)
+	if err != nil {
+		return nil, err
+	}
+	verifier.pubKey = key
+	return verifier, nil
+}
+
+// decodePubKey decodes a base64 ed25519 key and checks it belongs to address
+func decodePubKey(value string, address string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %v", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid pubkey: expected %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+
+	// the validator address is the first 20 bytes of sha256(pubkey)
+	sum := sha256.Sum256(raw)
+	if derived := strings.ToUpper(hex.EncodeToString(sum[:20])); derived != strings.ToUpper(address) {
+		return nil, fmt.Errorf("pubkey belongs to %s, not %s", derived, address)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func (v *SignatureVerifier) publicKey(height int) (ed25519.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.pubKey != nil {
+		return v.pubKey, nil
+	}
+
+	validators, err := api.GetValidators(v.chainID, v.pool, height)
+	if err != nil {
+		return nil, err
+	}
+	for _, validator := range validators {
+		if validator.Address != v.address {
+			continue
+		}
+		if validator.PubKey.Type != ed25519PubKeyType {
+			return nil, fmt.Errorf("unsupported pubkey type %s", validator.PubKey.Type)
+		}
+		key, err := decodePubKey(validator.PubKey.Value, v.address)
+		if err != nil {
+			return nil, err
+		}
+		v.pubKey = key
+		return key, nil
+	}
+	return nil, fmt.Errorf("validator %s not in the validator set at height %d", v.address, height)
+}
+
+// Verify checks the validators precommit in block and returns its verification status
+func (v *SignatureVerifier) Verify(block api.BlockSignature) int {
+	if block.Signature == "" || (block.BlockIDFlag != api.BlockIDFlagCommit && block.BlockIDFlag != api.BlockIDFlagNil) {
+		return VerificationSkipped
+	}
+
+	key, err := v.publicKey(block.CommitHeight)
+	if err != nil {
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: v.chainID, Operation: "VerifySignature", Height: block.CommitHeight, Success: false, Message: err.Error()})
+		return VerificationUnverifiable
+	}
+
+	signBytes, err := precommitSignBytes(v.chainID, block)
+	if err != nil {
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: v.chainID, Operation: "VerifySignature", Height: block.CommitHeight, Success: false, Message: err.Error()})
+		return VerificationUnverifiable
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(block.Signature)
+	if err != nil || !ed25519.Verify(key, signBytes, signature) {
+		logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: v.chainID, Operation: "VerifySignature", Height: block.CommitHeight, Success: false, Message: fmt.Sprintf("Invalid precommit signature for %s", v.address)})
+		return VerificationInvalid
+	}
+	return VerificationValid
+}
+
+// precommitSignBytes rebuilds the length-prefixed protobuf encoding of the CanonicalVote the validator signed
+func precommitSignBytes(chainID string, block api.BlockSignature) ([]byte, error) {
+	timestamp, err := time.Parse(time.RFC3339Nano, block.ValidatorTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid precommit timestamp: %v", err)
+	}
+
+	var vote []byte
+	vote = appendVarintField(vote, 1, precommitType)
+	vote = appendFixed64Field(vote, 2, uint64(block.CommitHeight))
+	vote = appendFixed64Field(vote, 3, uint64(block.CommitRound))
+
+	// a nil precommit carries no block id
+	if block.BlockIDFlag == api.BlockIDFlagCommit {
+		blockID, err := canonicalBlockID(block.CommitBlockID)
+		if err != nil {
+			return nil, err
+		}
+		vote = appendBytesField(vote, 4, blockID)
+	}
+
+	var ts []byte
+	ts = appendVarintField(ts, 1, uint64(timestamp.Unix()))
+	ts = appendVarintField(ts, 2, uint64(timestamp.Nanosecond()))
+	// the timestamp is non-nullable so it is always encoded
+	vote = appendTag(vote, 5, 2)
+	vote = binary.AppendUvarint(vote, uint64(len(ts)))
+	vote = append(vote, ts...)
+
+	vote = appendBytesField(vote, 6, []byte(chainID))
+
+	return append(binary.AppendUvarint(nil, uint64(len(vote))), vote...), nil
+}
+
+func canonicalBlockID(blockID api.BlockID) ([]byte, error) {
+	hash, err := hex.DecodeString(blockID.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block id hash: %v", err)
+	}
+	partsHash, err := hex.DecodeString(blockID.Parts.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid part set hash: %v", err)
+	}
+
+	var parts []byte
+	parts = appendVarintField(parts, 1, uint64(blockID.Parts.Total))
+	parts = appendBytesField(parts, 2, partsHash)
+
+	var encoded []byte
+	encoded = appendBytesField(encoded, 1, hash)
+	// the part set header is non-nullable so it is always encoded
+	encoded = appendTag(encoded, 2, 2)
+	encoded = binary.AppendUvarint(encoded, uint64(len(parts)))
+	encoded = append(encoded, parts...)
+	return encoded, nil
+}
+
+// protobuf helpers, proto3 omits fields holding the zero value
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarintField(b []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = appendTag(b, field, 0)
+	return binary.AppendUvarint(b, value)
+}
+
+func appendFixed64Field(b []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = appendTag(b, field, 1)
+	return binary.LittleEndian.AppendUint64(b, value)
+}
+
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = appendTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
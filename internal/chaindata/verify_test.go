@@ -0,0 +1,252 @@
+package chaindata
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+
+	"cometbftsignrate/internal/api"
+)
+
+func TestMain(m *testing.M) {
+	// Verify logs every invalid signature, keep the test output to the results
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func testBlockID() api.BlockID {
+	var blockID api.BlockID
+	blockID.Hash = "8B01023386C371778ECB6368573E539AFC3CC860EC3A2F614E54FE5652F4FC80"
+	blockID.Parts.Total = 2
+	blockID.Parts.Hash = "72DB3D959635DFF1BB567BEDAA70573392C5150ABE9A5C3E51A5E1F6B98D8CA0"
+	return blockID
+}
+
+// TestPrecommitSignBytesVector checks the encoding against the nil precommit vector of
+// TestVoteSignBytesTestVectors in cometbft/types/vote_test.go
+func TestPrecommitSignBytesVector(t *testing.T) {
+	got, err := precommitSignBytes("", api.BlockSignature{
+		CommitHeight:       1,
+		CommitRound:        1,
+		BlockIDFlag:        api.BlockIDFlagNil,
+		ValidatorTimestamp: "0001-01-01T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x21,                                   // length
+		0x8,                                    // (field_number << 3) | wire_type
+		0x2,                                    // PrecommitType
+		0x11,                                   // (field_number << 3) | wire_type
+		0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // height
+		0x19,                                   // (field_number << 3) | wire_type
+		0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // round
+		0x2a, // (field_number << 3) | wire_type
+		// remaining fields (timestamp):
+		0xb, 0x8, 0x80, 0x92, 0xb8, 0xc3, 0x98, 0xfe, 0xff, 0xff, 0xff, 0x1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+// canonicalVoteDescriptor describes CanonicalVote of cometbft/proto/tendermint/types/canonical.proto
+func canonicalVoteDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     fieldType.Enum(),
+			JsonName: proto.String(name),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("canonical.proto"),
+		Package:    proto.String("tendermint.types"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("CanonicalPartSetHeader"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("total", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+					field("hash", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+				},
+			},
+			{
+				Name: proto.String("CanonicalBlockID"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("hash", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+					field("part_set_header", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".tendermint.types.CanonicalPartSetHeader"),
+				},
+			},
+			{
+				Name: proto.String("CanonicalVote"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					// SignedMsgType is an enum, encoded like an int32
+					field("type", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+					field("height", 2, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64, ""),
+					field("round", 3, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64, ""),
+					field("block_id", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".tendermint.types.CanonicalBlockID"),
+					field("timestamp", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+					field("chain_id", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+		},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("CanonicalVote")
+}
+
+// marshalCanonicalVote encodes the precommit with the protobuf library, length-prefixed like the sign bytes
+func marshalCanonicalVote(t *testing.T, chainID string, block api.BlockSignature) []byte {
+	t.Helper()
+	descriptor := canonicalVoteDescriptor(t)
+	fields := descriptor.Fields()
+	vote := dynamicpb.NewMessage(descriptor)
+	vote.Set(fields.ByName("type"), protoreflect.ValueOfInt32(precommitType))
+	vote.Set(fields.ByName("height"), protoreflect.ValueOfInt64(int64(block.CommitHeight)))
+	vote.Set(fields.ByName("round"), protoreflect.ValueOfInt64(int64(block.CommitRound)))
+	if block.BlockIDFlag == api.BlockIDFlagCommit {
+		hash, _ := hex.DecodeString(block.CommitBlockID.Hash)
+		partsHash, _ := hex.DecodeString(block.CommitBlockID.Parts.Hash)
+		blockID := vote.Mutable(fields.ByName("block_id")).Message()
+		blockID.Set(blockID.Descriptor().Fields().ByName("hash"), protoreflect.ValueOfBytes(hash))
+		parts := blockID.Mutable(blockID.Descriptor().Fields().ByName("part_set_header")).Message()
+		parts.Set(parts.Descriptor().Fields().ByName("total"), protoreflect.ValueOfUint32(uint32(block.CommitBlockID.Parts.Total)))
+		parts.Set(parts.Descriptor().Fields().ByName("hash"), protoreflect.ValueOfBytes(partsHash))
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, block.ValidatorTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := vote.Mutable(fields.ByName("timestamp")).Message()
+	ts.Set(ts.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(timestamp.Unix()))
+	ts.Set(ts.Descriptor().Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(timestamp.Nanosecond())))
+	vote.Set(fields.ByName("chain_id"), protoreflect.ValueOfString(chainID))
+
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(vote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(protowire.AppendVarint(nil, uint64(len(encoded))), encoded...)
+}
+
+func TestPrecommitSignBytesMatchProtobuf(t *testing.T) {
+	tests := []struct {
+		name  string
+		block api.BlockSignature
+	}{
+		{"commit", api.BlockSignature{
+			CommitHeight:       19283746,
+			CommitRound:        0,
+			BlockIDFlag:        api.BlockIDFlagCommit,
+			CommitBlockID:      testBlockID(),
+			ValidatorTimestamp: "2024-05-17T09:41:12.483920174Z",
+		}},
+		{"commit in a later round", api.BlockSignature{
+			CommitHeight:       7,
+			CommitRound:        3,
+			BlockIDFlag:        api.BlockIDFlagCommit,
+			CommitBlockID:      testBlockID(),
+			ValidatorTimestamp: "2024-05-17T09:41:12Z",
+		}},
+		{"nil vote", api.BlockSignature{
+			CommitHeight:       19283746,
+			CommitRound:        1,
+			BlockIDFlag:        api.BlockIDFlagNil,
+			ValidatorTimestamp: "2024-05-17T09:41:12.5Z",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := precommitSignBytes("cosmoshub-4", test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := marshalCanonicalVote(t, "cosmoshub-4", test.block); !bytes.Equal(got, want) {
+				t.Errorf("got %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(pub)
+	address := strings.ToUpper(hex.EncodeToString(sum[:20]))
+	verifier, err := NewSignatureVerifier("cosmoshub-4", address, base64.StdEncoding.EncodeToString(pub), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(block api.BlockSignature) api.BlockSignature {
+		block.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, marshalCanonicalVote(t, "cosmoshub-4", block)))
+		return block
+	}
+	commit := signed(api.BlockSignature{
+		CommitHeight:       100,
+		BlockIDFlag:        api.BlockIDFlagCommit,
+		CommitBlockID:      testBlockID(),
+		ValidatorTimestamp: "2024-05-17T09:41:12.483920174Z",
+	})
+	nilVote := signed(api.BlockSignature{
+		CommitHeight:       100,
+		CommitRound:        1,
+		BlockIDFlag:        api.BlockIDFlagNil,
+		ValidatorTimestamp: "2024-05-17T09:41:12.483920174Z",
+	})
+	// the signature of a nil vote does not cover a block id
+	wrongFlag := nilVote
+	wrongFlag.BlockIDFlag = api.BlockIDFlagCommit
+	wrongFlag.CommitBlockID = testBlockID()
+	otherHeight := commit
+	otherHeight.CommitHeight++
+	absent := api.BlockSignature{CommitHeight: 100, BlockIDFlag: api.BlockIDFlagAbsent}
+
+	tests := []struct {
+		name  string
+		block api.BlockSignature
+		want  int
+	}{
+		{"commit", commit, VerificationValid},
+		{"nil vote", nilVote, VerificationValid},
+		{"nil vote verified as a commit", wrongFlag, VerificationInvalid},
+		{"other height", otherHeight, VerificationInvalid},
+		{"absent", absent, VerificationSkipped},
+	}
+	for _, test := range tests {
+		if got := verifier.Verify(test.block); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
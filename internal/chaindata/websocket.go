@@ -0,0 +1,139 @@
+package chaindata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/db_utils"
+	"cometbftsignrate/internal/logger"
+	"cometbftsignrate/internal/supervisor"
+)
+
+// processChainWebsocket stores blocks as they are pushed by the nodes NewBlock subscription.
+// Before every (re)connect the chain is caught up by polling so gaps left by a disconnect are filled.
+func processChainWebsocket(ctx context.Context, chain Chain, db db_utils.Store, initialScan int, sleepDuration int) error {
+	for {
+		lastHeight, err := catchUp(ctx, chain, db, initialScan)
+		if api.IsFatal(err) {
+			return err
+		}
+		if err != nil {
+			// catch up failed part way, retry it before subscribing so the gap is not skipped
+			supervisor.SetDegraded(ctx, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Duration(sleepDuration) * time.Second):
+			}
+			continue
+		}
+		supervisor.SetHealthy(ctx)
+
+		blocks := make(chan api.Block, 16)
+		subCtx, cancelSub := context.WithCancel(ctx)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- api.SubscribeNewBlocks(subCtx, chain.Pool, blocks)
+		}()
+
+		lastHeight, err = consumeBlocks(ctx, chain, db, blocks, errCh, lastHeight, sleepDuration)
+		cancelSub()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "SubscribeNewBlocks", Height: lastHeight, Success: false, Message: fmt.Sprintf("Websocket subscription ended, falling back to polling in %d seconds: %v", sleepDuration, err)})
+		supervisor.SetDegraded(ctx, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(sleepDuration) * time.Second):
+		}
+	}
+}
+
+// how often a subscription checks node health and prunes when rest_period is not set
+const defaultHealthCheckInterval = 10 * time.Second
+
+// consumeBlocks stores pushed blocks until the subscription ends, returns the last committed height and the reason
+// the subscription ended, nil only if ctx was cancelled.
+// Blocks that arrive in a burst share a transaction, it is committed once no further block is queued.
+func consumeBlocks(ctx context.Context, chain Chain, db db_utils.Store, blocks <-chan api.Block, errCh <-chan error, lastHeight int, sleepDuration int) (int, error) {
+	interval := time.Duration(sleepDuration) * time.Second
+	if interval <= 0 {
+		// rest_period may be 0 to poll without pausing, the ticker needs a positive interval
+		interval = defaultHealthCheckInterval
+	}
+	pruneTicker := time.NewTicker(interval)
+	defer pruneTicker.Stop()
+
+	batch := newBlockBatch(chain, db, lastHeight)
+	// commits the open batch and returns the last committed height
+	flush := func() int {
+		if err := batch.commit(); err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chain.ChainID, Operation: "CommitBatch", Success: false, Message: err.Error()})
+		}
+		return batch.committedHeight
+	}
+	defer flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return flush(), nil
+		case err := <-errCh:
+			if err == nil {
+				err = fmt.Errorf("websocket subscription ended")
+			}
+			return flush(), err
+		case <-pruneTicker.C:
+			lastHeight = flush()
+			// pushed blocks do not tell whether the node fell behind, fall back to polling which waits for it
+			status, err := api.GetChainStatus(chain.ChainID, chain.Pool)
+			if err == nil {
+				err = checkNodeHealth(chain, status)
+			}
+			if err != nil {
+				return flush(), err
+			}
+			if chain.PruningEnabled {
+				pruneChain(chain, db)
+			}
+			scanGaps(ctx, chain, db)
+		case block := <-blocks:
+			height, err := api.BlockHeight(block)
+			if err != nil {
+				logger.PostLog("ERROR", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "SubscribeNewBlocks", Success: false, Message: fmt.Sprintf("Invalid block height in event: %v", err)})
+				continue
+			}
+			if height <= lastHeight {
+				continue
+			}
+
+			// missed events between the last stored block and this one are fetched by polling
+			if height > lastHeight+1 {
+				logger.PostLog("WARN", logger.ModuleHTTP{ChainID: chain.ChainID, Operation: "SubscribeNewBlocks", Height: height, Message: fmt.Sprintf("Filling gap of %d blocks", height-lastHeight-1)})
+				// the gap is written in its own batches after the heights below it
+				if lastHeight = flush(); lastHeight+1 < height {
+					lastHeight, err = syncRange(ctx, chain, db, lastHeight+1, height)
+					if err != nil {
+						// the gap could not be filled, let the polling fallback retry it
+						return lastHeight, err
+					}
+				}
+				batch = newBlockBatch(chain, db, lastHeight)
+			}
+
+			if err := batch.store(height, block, true); err != nil {
+				return flush(), err
+			}
+			lastHeight = height
+			if len(blocks) == 0 {
+				lastHeight = flush()
+			}
+		}
+	}
+}
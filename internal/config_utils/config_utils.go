@@ -17,16 +17,41 @@ type Config struct {
 type ChainConfig struct {
 	ChainID    string `toml:"chain_id"`
 	HostAddress    string `toml:"host"`
+	Hosts []string `toml:"hosts"`
 	HexAddress string `toml:"address"`
 	RPCdelay string `toml:"rpc_delay"`
 	SigningWindow int `toml:"signing_window"`
 	PruningEnabled bool `toml:"pruning"`
+	IngestMode string `toml:"ingest_mode"`
+	Concurrency int `toml:"concurrency"`
+	VerifySignatures bool `toml:"verify_signatures"`
+	PubKey string `toml:"pubkey"`
+	Addresses []ValidatorConfig `toml:"addresses"`
+	TrackValidatorSet bool `toml:"track_validator_set"`
+	NetworkMissThreshold float64 `toml:"network_miss_threshold"`
+	RestAddress string `toml:"rest"`
+	Bech32Prefix string `toml:"bech32_prefix"`
+	ReconcileInterval string `toml:"reconcile_interval"`
+	GapScanInterval string `toml:"gap_scan_interval"`
+	RateLimit float64 `toml:"rate_limit"`
+	ArchiveHost string `toml:"archive_host"`
+	MaxNodeLag string `toml:"max_node_lag"`
+	StallTimeout string `toml:"stall_timeout"`
+	BatchSize int `toml:"batch_size"`
+	BatchTimeout string `toml:"batch_timeout"`
+}
+
+type ValidatorConfig struct {
+	Address string `toml:"address"`
+	Name string `toml:"name"`
+	PubKey string `toml:"pubkey"`
 }
 
 type GlobalChainConfig struct {
 	RestPeriod int `toml:"rest_period"`
 	InitialScan int `toml:"initial_scan"`
 	DbLocation string `toml:"db_location"`
+	DbURL string `toml:"db_url"`
 	HttpPort int `toml:"http_port"`
 }
 
@@ -34,6 +59,8 @@ func SetDefaultChainConfig() *ChainConfig {
 	return &ChainConfig{
 		RPCdelay: "0ms",
 		PruningEnabled: true,
+		IngestMode: "poll",
+		Concurrency: 1,
 	}
 }
 
@@ -57,6 +84,49 @@ func ParseConfig(filename string) (*Config, error) {
 	return &config, nil
 }
 
+// Validators returns every validator tracked on the chain, `address` and `addresses` are merged
+func (c ChainConfig) Validators() []ValidatorConfig {
+	var validators []ValidatorConfig
+	if c.HexAddress != "" {
+		validators = append(validators, ValidatorConfig{Address: c.HexAddress, PubKey: c.PubKey})
+	}
+	for _, validator := range c.Addresses {
+		if validator.Address != c.HexAddress {
+			validators = append(validators, validator)
+		}
+	}
+	return validators
+}
+
+// FindValidator returns the tracked validator matching address or name
+func (c ChainConfig) FindValidator(addressOrName string) (ValidatorConfig, bool) {
+	for _, validator := range c.Validators() {
+		if validator.Address == addressOrName || (validator.Name != "" && validator.Name == addressOrName) {
+			return validator, true
+		}
+	}
+	return ValidatorConfig{}, false
+}
+
+// SigningWindows returns the signing_window of every chain by chain_id
+func (c *Config) SigningWindows() map[string]int {
+	windows := make(map[string]int)
+	for _, chain := range c.Chains {
+		windows[chain.ChainID] = chain.SigningWindow
+	}
+	return windows
+}
+
+// GetChain returns the config of chainID
+func GetChain(chainID string) (ChainConfig, bool) {
+	for _, chain := range ChainsData {
+		if chain.ChainID == chainID {
+			return chain, true
+		}
+	}
+	return ChainConfig{}, false
+}
+
 func SetChains(config *Config) {
 	// Set global chain config
 	ChainsData = append(ChainsData, config.Chains...)
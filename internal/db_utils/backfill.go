@@ -0,0 +1,86 @@
+package db_utils
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// BackfillCheckpoint is the progress of a backfill of [FromHeight, ToHeight], every height below NextHeight is stored
+type BackfillCheckpoint struct {
+	ChainID    string
+	FromHeight int
+	ToHeight   int
+	NextHeight int
+	UpdatedAt  string
+}
+
+// Done reports whether every height of the backfill is stored
+func (c BackfillCheckpoint) Done() bool {
+	return c.NextHeight > c.ToHeight
+}
+
+func createBackfillTable(db *sqlStore) error {
+	createTableSQL := `CREATE TABLE IF NOT EXISTS backfill_checkpoints (
+		chain_id TEXT NOT NULL,
+		from_height INTEGER NOT NULL,
+		to_height INTEGER NOT NULL,
+		next_height INTEGER NOT NULL,
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (chain_id, from_height, to_height)
+	 );`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create backfill_checkpoints table: %v", err)
+	}
+	return nil
+}
+
+// GetBackfillCheckpoint returns the checkpoint of the backfill of [fromHeight, toHeight], false if it never ran
+func (db *sqlStore) GetBackfillCheckpoint(chainID string, fromHeight int, toHeight int) (BackfillCheckpoint, bool, error) {
+	querySQL := `SELECT chain_id, from_height, to_height, next_height, updated_at FROM backfill_checkpoints WHERE chain_id = ? AND from_height = ? AND to_height = ?`
+	var checkpoint BackfillCheckpoint
+	err := db.QueryRow(querySQL, chainID, fromHeight, toHeight).Scan(&checkpoint.ChainID, &checkpoint.FromHeight, &checkpoint.ToHeight, &checkpoint.NextHeight, &checkpoint.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return BackfillCheckpoint{}, false, nil
+		}
+		return BackfillCheckpoint{}, false, fmt.Errorf("failed to get backfill checkpoint for chain_id %s: %v", chainID, err)
+	}
+	return checkpoint, true, nil
+}
+
+// SaveBackfillCheckpoint stores the progress of a backfill
+func (db *sqlStore) SaveBackfillCheckpoint(checkpoint BackfillCheckpoint) error {
+	upsertSQL := `INSERT INTO backfill_checkpoints (chain_id, from_height, to_height, next_height, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (chain_id, from_height, to_height) DO UPDATE SET next_height = excluded.next_height, updated_at = excluded.updated_at`
+	_, err := db.Exec(upsertSQL, checkpoint.ChainID, checkpoint.FromHeight, checkpoint.ToHeight, checkpoint.NextHeight, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save backfill checkpoint for chain_id %s: %v", checkpoint.ChainID, err)
+	}
+	return nil
+}
+
+// GetPendingBackfills returns the unfinished backfills of chainID, oldest range first
+func (db *sqlStore) GetPendingBackfills(chainID string) ([]BackfillCheckpoint, error) {
+	querySQL := `SELECT chain_id, from_height, to_height, next_height, updated_at FROM backfill_checkpoints
+		WHERE chain_id = ? AND next_height <= to_height
+		ORDER BY from_height`
+	rows, err := db.Query(querySQL, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending backfills for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	var checkpoints []BackfillCheckpoint
+	for rows.Next() {
+		var checkpoint BackfillCheckpoint
+		if err := rows.Scan(&checkpoint.ChainID, &checkpoint.FromHeight, &checkpoint.ToHeight, &checkpoint.NextHeight, &checkpoint.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to get pending backfills for chain_id %s: %v", chainID, err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
@@ -8,7 +8,7 @@ import (
 	"time"
 )
 
-func GetLastBlockHeight(db *sql.DB, chainID string, currentNodeHeight int, signingWindow int, pruningEnabled bool) (int, error) {
+func (db *sqlStore) GetLastBlockHeight(chainID string, currentNodeHeight int, signingWindow int, pruningEnabled bool) (int, error) {
 	// Get the latest block height for the given chain_id from DB
 	var blockHeight int
 	querySQL := `SELECT block_height FROM cometbft_signatures WHERE chain_id = ? ORDER BY block_height DESC LIMIT 1`
@@ -31,7 +31,7 @@ func GetLastBlockHeight(db *sql.DB, chainID string, currentNodeHeight int, signi
 	return blockHeight, nil
 }
 
-func GetAmountOfSignatureNotFound(db *sql.DB, chainID string, numRecords int) (int, string, error) {
+func (db *sqlStore) GetAmountOfSignatureNotFound(chainID string, address string, numRecords int) (int, string, error) {
 	// Check if the chain_id exists in the database
 	var exists bool
 	querySQL := `
@@ -53,21 +53,18 @@ func GetAmountOfSignatureNotFound(db *sql.DB, chainID string, numRecords int) (i
 		return 0, "", fmt.Errorf("chain_id %s not found", chainID)
 	}
 
-	// Get the amount of signatures not found
+	// Get the amount of signatures not found for the last numRecords commit heights
 	var count int
 	querySQL = `
 		SELECT COUNT(*) 
-		FROM (
-			SELECT *
-			FROM cometbft_signatures 
-			WHERE chain_id = ? 
-			ORDER BY block_height DESC
-			LIMIT ?
-		) AS latest_signatures
-		WHERE signatureFound = 0;
+		FROM cometbft_signatures 
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND signatureFound = 0
+			AND in_active_set = 1;
 	`
 
-	err = db.QueryRow(querySQL, chainID, numRecords).Scan(&count)
+	err = db.QueryRow(querySQL, chainID, address, chainID, address, numRecords).Scan(&count)
 	if err != nil {
 		return 0, "", fmt.Errorf("failed to get amount of signatures not found: %v", err)
 	}
@@ -89,45 +86,62 @@ func GetAmountOfSignatureNotFound(db *sql.DB, chainID string, numRecords int) (i
 	return count, timestamp, nil
 }
 
-func GetTimestampDiff(db *sql.DB, chainID string, address string) (int, error) {
+func (db *sqlStore) GetTimestampDiff(chainID string, address string) (int, error) {
 	// Get the proposer timestamp and validator timestamp for the given chain_id and address
-	// Average diff for last 25 blocks
-	querySQL := `SELECT timestamp, validatortimestamp FROM cometbft_signatures WHERE chain_id = ? AND address = ? ORDER BY block_height DESC LIMIT 25`
+	// Average diff for last 25 blocks the validator signed, a miss has no validator timestamp
+	querySQL := `SELECT timestamp, validatortimestamp FROM cometbft_signatures WHERE chain_id = ? AND address = ? AND validatortimestamp <> '' ORDER BY block_height DESC LIMIT 25`
 	rows, err := db.Query(querySQL, chainID, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get timestamps for chain_id %s: %v", chainID, err)
+	}
 	defer rows.Close()
 
-	var average int
-	if rows.Err() != nil {
-		timeDiffArray := []int{}
-		for rows.Next() {
-			var proposerTimestamp string
-			var validatorTimestamp string
-			err = rows.Scan(&proposerTimestamp, &validatorTimestamp)
-			if err != nil {
-				logger.PostLog("ERROR", fmt.Sprintf("failed to scan proposer and validator timestamps: %v", err))
-			}
-
-			propTime, err := time.Parse(time.RFC3339, proposerTimestamp)
-			if err != nil {
-				logger.PostLog("ERROR", fmt.Sprintf("failed to parse proposer timestamp: %v", err))
-			}
-			valTime, err := time.Parse(time.RFC3339, validatorTimestamp)
-			if err != nil {
-				logger.PostLog("ERROR", fmt.Sprintf("failed to parse validator timestamp: %v", err))
-			}
-			timeDiffArray = append(timeDiffArray, int(valTime.Sub(propTime).Milliseconds()))
+	timeDiffArray := []int{}
+	for rows.Next() {
+		var proposerTimestamp string
+		var validatorTimestamp string
+		if err := rows.Scan(&proposerTimestamp, &validatorTimestamp); err != nil {
+			return 0, fmt.Errorf("failed to scan proposer and validator timestamps: %v", err)
 		}
-
-		// Calculate the average time difference
-		var sum int
-		for _, diff := range timeDiffArray {
-			sum += diff
+		diff, err := timestampDiff(proposerTimestamp, validatorTimestamp)
+		if err != nil {
+			logger.PostLog("ERROR", err.Error())
+			continue
 		}
-		average = sum / len(timeDiffArray)
+		timeDiffArray = append(timeDiffArray, diff)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get timestamps for chain_id %s: %v", chainID, err)
+	}
+	return averageDiff(timeDiffArray), nil
+}
+
+// timestampDiff returns how many milliseconds the validator timestamp is after the proposer timestamp
+func timestampDiff(proposerTimestamp string, validatorTimestamp string) (int, error) {
+	propTime, err := time.Parse(time.RFC3339, proposerTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse proposer timestamp: %v", err)
+	}
+	valTime, err := time.Parse(time.RFC3339, validatorTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse validator timestamp: %v", err)
 	}
-	return average, nil
+	return int(valTime.Sub(propTime).Milliseconds()), nil
 }
-func GetNumberOfRecordsForChain(db *sql.DB, chainID string) (int, error) {
+
+// averageDiff returns the average of diffs, 0 if there are none
+func averageDiff(diffs []int) int {
+	if len(diffs) == 0 {
+		return 0
+	}
+	var sum int
+	for _, diff := range diffs {
+		sum += diff
+	}
+	return sum / len(diffs)
+}
+
+func (db *sqlStore) GetNumberOfRecordsForChain(chainID string) (int, error) {
 	// Get the number of records for the given chain_id
 	var count int
 	querySQL := `SELECT COUNT(*) FROM cometbft_signatures WHERE chain_id = ?`
@@ -138,7 +152,18 @@ func GetNumberOfRecordsForChain(db *sql.DB, chainID string) (int, error) {
 	return count, nil
 }
 
-func GetNumberOfProposedBlocks(db *sql.DB, chainID string, address string, window int) (int, error) {
+func (db *sqlStore) GetNumberOfRecordsForValidator(chainID string, address string) (int, error) {
+	// Get the number of records for the given chain_id and address
+	var count int
+	querySQL := `SELECT COUNT(*) FROM cometbft_signatures WHERE chain_id = ? AND address = ?`
+	err := db.QueryRow(querySQL, chainID, address).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get number of records for chain_id %s address %s: %v", chainID, address, err)
+	}
+	return count, nil
+}
+
+func (db *sqlStore) GetNumberOfProposedBlocks(chainID string, address string, window int) (int, error) {
 	// Scan the last X rows and count how many have proposermatch = 1
 	var count int
 	querySQL := `
@@ -158,7 +183,7 @@ func GetNumberOfProposedBlocks(db *sql.DB, chainID string, address string, windo
 	return count, nil
 }
 
-func GetNumberOfEmptyProposedBlocks(db *sql.DB, chainID string, address string, window int) (int, error) {
+func (db *sqlStore) GetNumberOfEmptyProposedBlocks(chainID string, address string, window int) (int, error) {
 	// Scan the last X rows and count how many have proposermatch = 1 and numtxs = 0
 	var count int
 	querySQL := `
@@ -177,3 +202,190 @@ func GetNumberOfEmptyProposedBlocks(db *sql.DB, chainID string, address string,
 	}
 	return count, nil
 }
+
+func (db *sqlStore) GetBlockIDFlagCounts(chainID string, address string, window int) (int, int, int, error) {
+	// Count committed, nil-voted and absent signatures for the last X commit heights the validator was in the active set
+	var committed, nilVoted, absent int
+	querySQL := `
+		SELECT
+			COALESCE(SUM(CASE WHEN block_id_flag = 2 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN block_id_flag = 3 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN block_id_flag NOT IN (2, 3) THEN 1 ELSE 0 END), 0)
+		FROM cometbft_signatures
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND in_active_set = 1`
+	err := db.QueryRow(querySQL, chainID, address, chainID, address, window).Scan(&committed, &nilVoted, &absent)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count block id flags for chain_id %s: %v", chainID, err)
+	}
+	return committed, nilVoted, absent, nil
+}
+
+func (db *sqlStore) GetInvalidSignatureCount(chainID string, address string, window int) (int, error) {
+	// Count signatures that failed ed25519 verification for the last X commit heights
+	var count int
+	querySQL := `
+		SELECT COUNT(*)
+		FROM cometbft_signatures
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND verification_status = 2`
+	err := db.QueryRow(querySQL, chainID, address, chainID, address, window).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count invalid signatures for chain_id %s: %v", chainID, err)
+	}
+	return count, nil
+}
+
+func (db *sqlStore) GetOutOfActiveSetCount(chainID string, address string, window int) (int, error) {
+	// Count the last X commit heights where the validator was not in the active set (jailed, unbonding or not bonded)
+	var count int
+	querySQL := `
+		SELECT COUNT(*)
+		FROM cometbft_signatures
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND in_active_set = 0`
+	err := db.QueryRow(querySQL, chainID, address, chainID, address, window).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count out of active set heights for chain_id %s: %v", chainID, err)
+	}
+	return count, nil
+}
+
+func (db *sqlStore) GetLatestActiveSetStatus(chainID string, address string) (bool, int64, error) {
+	// Get whether the validator was in the active set at the latest stored commit and with what voting power
+	var inActiveSet bool
+	var votingPower int64
+	querySQL := `SELECT in_active_set, voting_power FROM cometbft_signatures WHERE chain_id = ? AND address = ? ORDER BY block_height DESC LIMIT 1`
+	err := db.QueryRow(querySQL, chainID, address).Scan(&inActiveSet, &votingPower)
+	if err != nil && err != sql.ErrNoRows {
+		return false, 0, fmt.Errorf("failed to get active set status for chain_id %s: %v", chainID, err)
+	}
+	return inActiveSet, votingPower, nil
+}
+
+func (db *sqlStore) GetMissClassification(chainID string, address string, window int) (int, int, error) {
+	// Split the misses in the last X commit heights into isolated and network-wide ones, rows stored before
+	// network participation was recorded are in neither
+	var isolated, networkWide int
+	querySQL := `
+		SELECT
+			COALESCE(SUM(CASE WHEN isolated_miss = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN isolated_miss = 0 AND network_committed_fraction IS NOT NULL THEN 1 ELSE 0 END), 0)
+		FROM cometbft_signatures
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND signaturefound = 0
+			AND in_active_set = 1`
+	err := db.QueryRow(querySQL, chainID, address, chainID, address, window).Scan(&isolated, &networkWide)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to classify misses for chain_id %s: %v", chainID, err)
+	}
+	return isolated, networkWide, nil
+}
+
+func (db *sqlStore) GetCurrentConsecutiveMisses(chainID string, address string) (int, error) {
+	// Get the number of blocks in the open miss incident of the validator, 0 if it is signing
+	var count int
+	querySQL := `SELECT block_count FROM miss_incidents WHERE chain_id = ? AND address = ? AND status = 'open' ORDER BY start_height DESC LIMIT 1`
+	err := db.QueryRow(querySQL, chainID, address).Scan(&count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get consecutive misses for chain_id %s: %v", chainID, err)
+	}
+	return count, nil
+}
+
+func (db *sqlStore) GetAbsentCount(chainID string, address string, window int) (int, int, error) {
+	// Count the absent votes and the stored heights in the active set for the last X commit heights,
+	// x/slashing only counts absent votes as missed
+	var absent, records int
+	querySQL := `
+		SELECT
+			COALESCE(SUM(CASE WHEN block_id_flag NOT IN (2, 3) THEN 1 ELSE 0 END), 0),
+			COUNT(*)
+		FROM cometbft_signatures
+		WHERE chain_id = ? AND address = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?) - ?
+			AND in_active_set = 1`
+	err := db.QueryRow(querySQL, chainID, address, chainID, address, window).Scan(&absent, &records)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count absent votes for chain_id %s: %v", chainID, err)
+	}
+	return absent, records, nil
+}
+
+func (db *sqlStore) GetAverageBlockTime(chainID string, address string, samples int) (float64, error) {
+	// Average seconds between blocks over the last X stored heights, 0 if there are not enough records
+	querySQL := `SELECT block_height, timestamp FROM cometbft_signatures WHERE chain_id = ? AND address = ? ORDER BY block_height DESC LIMIT 1 OFFSET ?`
+	var latestHeight, oldestHeight int
+	var latestTimestamp, oldestTimestamp string
+	err := db.QueryRow(querySQL, chainID, address, 0).Scan(&latestHeight, &latestTimestamp)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get average block time for chain_id %s: %v", chainID, err)
+	}
+
+	// fall back to the oldest stored height when fewer than samples are stored
+	err = db.QueryRow(querySQL, chainID, address, samples-1).Scan(&oldestHeight, &oldestTimestamp)
+	if err == sql.ErrNoRows {
+		err = db.QueryRow(`SELECT block_height, timestamp FROM cometbft_signatures WHERE chain_id = ? AND address = ? ORDER BY block_height ASC LIMIT 1`, chainID, address).Scan(&oldestHeight, &oldestTimestamp)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get average block time for chain_id %s: %v", chainID, err)
+	}
+	if latestHeight <= oldestHeight {
+		return 0, nil
+	}
+
+	latestTime, err := time.Parse(time.RFC3339, latestTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block timestamp: %v", err)
+	}
+	oldestTime, err := time.Parse(time.RFC3339, oldestTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block timestamp: %v", err)
+	}
+	return latestTime.Sub(oldestTime).Seconds() / float64(latestHeight-oldestHeight), nil
+}
+
+func (db *sqlStore) GetLatestStoredHeight(chainID string, address string) (int, error) {
+	// Get the highest block height stored for the validator, 0 if there is none
+	var height sql.NullInt64
+	querySQL := `SELECT MAX(block_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?`
+	err := db.QueryRow(querySQL, chainID, address).Scan(&height)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest stored height for chain_id %s: %v", chainID, err)
+	}
+	return int(height.Int64), nil
+}
+
+func (db *sqlStore) GetSlashingWindowMisses(chainID string, address string, fromHeight int, toHeight int, window int) (int, int, error) {
+	// Count the absent votes in the last X block heights up to toHeight the validator was in the active set,
+	// x/slashing only advances its window at those heights
+	var absent, records int
+	querySQL := `
+		SELECT
+			COALESCE(SUM(CASE WHEN block_id_flag NOT IN (2, 3) THEN 1 ELSE 0 END), 0),
+			COUNT(*)
+		FROM (
+			SELECT block_id_flag
+			FROM cometbft_signatures
+			WHERE chain_id = ? AND address = ?
+				AND block_height >= ? AND block_height <= ?
+				AND in_active_set = 1
+			ORDER BY block_height DESC
+			LIMIT ?
+		) AS window_rows`
+	err := db.QueryRow(querySQL, chainID, address, fromHeight, toHeight, window).Scan(&absent, &records)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count slashing window misses for chain_id %s: %v", chainID, err)
+	}
+	return absent, records, nil
+}
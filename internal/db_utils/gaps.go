@@ -0,0 +1,70 @@
+package db_utils
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// HeightRange is an inclusive range of block heights
+type HeightRange struct {
+	From int
+	To   int
+}
+
+// Count returns the number of heights in the range
+func (r HeightRange) Count() int {
+	return r.To - r.From + 1
+}
+
+// GetStoredHeightRange returns the lowest and highest block height stored for chainID, 0 and 0 if there is none
+func (db *sqlStore) GetStoredHeightRange(chainID string) (int, int, error) {
+	var minHeight, maxHeight sql.NullInt64
+	querySQL := `SELECT MIN(block_height), MAX(block_height) FROM cometbft_signatures WHERE chain_id = ?`
+	err := db.QueryRow(querySQL, chainID).Scan(&minHeight, &maxHeight)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get stored height range for chain_id %s: %v", chainID, err)
+	}
+	return int(minHeight.Int64), int(maxHeight.Int64), nil
+}
+
+// GetMissingHeights returns the block heights in [fromHeight, toHeight] that have no record for address, as ascending ranges
+func (db *sqlStore) GetMissingHeights(chainID string, address string, fromHeight int, toHeight int) ([]HeightRange, error) {
+	if fromHeight > toHeight {
+		return nil, nil
+	}
+
+	// every stored height followed by a hole, the bounds of the range are added as sentinels so leading
+	// and trailing holes are found as well. PostgreSQL cannot infer the type of a bare parameter, hence the casts.
+	querySQL := `
+		SELECT block_height + 1, next_height - 1
+		FROM (
+			SELECT block_height, LEAD(block_height) OVER (ORDER BY block_height) AS next_height
+			FROM (
+				SELECT block_height FROM cometbft_signatures
+				WHERE chain_id = ? AND address = ? AND block_height >= ? AND block_height <= ?
+				UNION SELECT CAST(? AS INTEGER) - 1
+				UNION SELECT CAST(? AS INTEGER) + 1
+			) AS stored
+		) AS followed
+		WHERE next_height > block_height + 1`
+	rows, err := db.Query(querySQL, chainID, address, fromHeight, toHeight, fromHeight, toHeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missing heights for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	var gaps []HeightRange
+	for rows.Next() {
+		var gap HeightRange
+		if err := rows.Scan(&gap.From, &gap.To); err != nil {
+			return nil, fmt.Errorf("failed to scan missing heights for chain_id %s: %v", chainID, err)
+		}
+		gaps = append(gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get missing heights for chain_id %s: %v", chainID, err)
+	}
+	return gaps, nil
+}
@@ -0,0 +1,132 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Status of a miss incident
+const (
+	IncidentOpen     = "open"
+	IncidentResolved = "resolved"
+)
+
+// Incident is a streak of consecutive missed commit heights of one validator.
+// StartTime and EndTime are the block timestamps of the first and last missed height.
+type Incident struct {
+	ID             int64
+	ChainID        string
+	Address        string
+	StartHeight    int
+	EndHeight      int
+	StartTime      string
+	EndTime        string
+	BlockCount     int
+	Status         string
+	ResolvedHeight int
+}
+
+func createIncidentTable(db *sqlStore) error {
+	createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS miss_incidents (
+		id %s,
+		chain_id TEXT NOT NULL,
+		address TEXT NOT NULL,
+		start_height INTEGER NOT NULL,
+		end_height INTEGER NOT NULL,
+		start_time TEXT NOT NULL,
+		end_time TEXT NOT NULL,
+		block_count INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		resolved_height INTEGER NOT NULL DEFAULT 0
+	 );`, db.dialect.serialKey)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create miss_incidents table: %v", err)
+	}
+
+	indexSQL := `CREATE INDEX IF NOT EXISTS idx_miss_incidents_validator
+		ON miss_incidents (chain_id, address, status)`
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create miss_incidents index: %v", err)
+	}
+	return nil
+}
+
+// GetOpenIncident returns the open incident of address, false if it is currently signing
+func (db *sqlStore) GetOpenIncident(chainID string, address string) (Incident, bool, error) {
+	querySQL := `
+		SELECT id, chain_id, address, start_height, end_height, start_time, end_time, block_count, status, resolved_height
+		FROM miss_incidents
+		WHERE chain_id = ? AND address = ? AND status = ?
+		ORDER BY start_height DESC
+		LIMIT 1`
+	var incident Incident
+	err := db.QueryRow(querySQL, chainID, address, IncidentOpen).Scan(&incident.ID, &incident.ChainID, &incident.Address, &incident.StartHeight, &incident.EndHeight, &incident.StartTime, &incident.EndTime, &incident.BlockCount, &incident.Status, &incident.ResolvedHeight)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Incident{}, false, nil
+		}
+		return Incident{}, false, fmt.Errorf("failed to get open incident for chain_id %s: %v", chainID, err)
+	}
+	return incident, true, nil
+}
+
+// InsertIncident stores a new incident and returns its id
+func (db *sqlStore) InsertIncident(incident Incident) (int64, error) {
+	insertSQL := `INSERT INTO miss_incidents (chain_id, address, start_height, end_height, start_time, end_time, block_count, status, resolved_height)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+	var id int64
+	err := db.QueryRow(insertSQL, incident.ChainID, incident.Address, incident.StartHeight, incident.EndHeight, incident.StartTime, incident.EndTime, incident.BlockCount, incident.Status, incident.ResolvedHeight).Scan(&id)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: incident.ChainID, Operation: "InsertIncident", Height: incident.StartHeight, Success: false, Message: err.Error()})
+		return 0, fmt.Errorf("failed to insert incident: %v", err)
+	}
+	return id, nil
+}
+
+// UpdateIncident stores the end, block count and status of an existing incident
+func (db *sqlStore) UpdateIncident(incident Incident) error {
+	updateSQL := `UPDATE miss_incidents SET end_height = ?, end_time = ?, block_count = ?, status = ?, resolved_height = ? WHERE id = ?`
+	_, err := db.Exec(updateSQL, incident.EndHeight, incident.EndTime, incident.BlockCount, incident.Status, incident.ResolvedHeight, incident.ID)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: incident.ChainID, Operation: "UpdateIncident", Height: incident.EndHeight, Success: false, Message: err.Error()})
+		return fmt.Errorf("failed to update incident: %v", err)
+	}
+	return nil
+}
+
+// GetIncidents returns the incidents of chainID that overlap [from, to], newest first.
+// address, from, to and status are optional filters, from and to are RFC3339 timestamps.
+func (db *sqlStore) GetIncidents(chainID string, address string, from string, to string, status string) ([]Incident, error) {
+	querySQL := `
+		SELECT id, chain_id, address, start_height, end_height, start_time, end_time, block_count, status, resolved_height
+		FROM miss_incidents
+		WHERE chain_id = ?
+			AND (? = '' OR address = ?)
+			AND (? = '' OR end_time >= ?)
+			AND (? = '' OR start_time <= ?)
+			AND (? = '' OR status = ?)
+		ORDER BY start_height DESC`
+	rows, err := db.Query(querySQL, chainID, address, address, from, from, to, to, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incidents for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		var incident Incident
+		err := rows.Scan(&incident.ID, &incident.ChainID, &incident.Address, &incident.StartHeight, &incident.EndHeight, &incident.StartTime, &incident.EndTime, &incident.BlockCount, &incident.Status, &incident.ResolvedHeight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incidents for chain_id %s: %v", chainID, err)
+		}
+		incidents = append(incidents, incident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get incidents for chain_id %s: %v", chainID, err)
+	}
+	return incidents, nil
+}
@@ -4,47 +4,87 @@ import (
 	"cometbftsignrate/internal/logger"
 	"database/sql"
 	"fmt"
+	"strings"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// initDB initializes the database and creates the table if it doesn't exist.
-func InitDB(dbFile string) (*sql.DB, error) {
+// milliseconds a write waits for the lock held by another connection or process
+const busyTimeoutMs = 10000
+
+// initDB opens the store and migrates it to the latest schema version.
+func InitDB(dbURL string, dbFile string, signingWindows map[string]int) (Store, error) {
 	logger.PostLog("INFO", "Initializing database...")
 
+	db, err := OpenDB(dbURL, dbFile, signingWindows)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrator, ok := db.(Migrator); ok {
+		if err := migrator.Migrate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// OpenDB opens the store without migrating it. dbURL selects the backend, postgres:// or postgresql:// for PostgreSQL,
+// sqlite://<path> for SQLite and memory://<snapshot path> for the in-memory store, the SQLite file dbFile is used if it is empty.
+// The in-memory store keeps the last signingWindows[chain_id] heights of every chain.
+func OpenDB(dbURL string, dbFile string, signingWindows map[string]int) (Store, error) {
+	switch {
+	case dbURL == "":
+		return openSQLite(dbFile)
+	case strings.HasPrefix(dbURL, "sqlite://"):
+		return openSQLite(strings.TrimPrefix(dbURL, "sqlite://"))
+	case strings.HasPrefix(dbURL, "postgres://"), strings.HasPrefix(dbURL, "postgresql://"):
+		return openPostgres(dbURL)
+	case IsMemoryURL(dbURL):
+		return openMemory(strings.TrimPrefix(dbURL, "memory://"), signingWindows)
+	default:
+		return nil, fmt.Errorf("unsupported db_url %q, expected postgres://, sqlite:// or memory://", dbURL)
+	}
+}
+
+// IsMemoryURL reports whether dbURL selects the in-memory store, which only lives in the process that opened it
+func IsMemoryURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "memory://")
+}
+
+func openSQLite(dbFile string) (Store, error) {
 	if dbFile == "" {
 		return nil, fmt.Errorf("dbFile is empty")
 	}
 
-	db, err := sql.Open("sqlite3", dbFile)
+	// WAL lets the API read while blocks are written, and the busy timeout lets a backfill or repair
+	// run against the DB of a live daemon instead of failing on its write lock. Transactions take the
+	// write lock when they begin, a transaction that reads first could not wait for it once another commits.
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", dbFile, busyTimeoutMs))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
+	return newSQLStore(db, sqliteDialect), nil
+}
 
-	// Create or update the table with the new columns
-	createTableSQL := `CREATE TABLE IF NOT EXISTS cometbft_signatures (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		timestamp TEXT NOT NULL,
-		chain_id TEXT NOT NULL,
-		address TEXT NOT NULL,
-		block_height INTEGER NOT NULL,
-		validatortimestamp TEXT NOT NULL,
-		signature TEXT NOT NULL,
-		signaturefound INTEGER NOT NULL DEFAULT 0,
-		proposermatch INTEGER NOT NULL DEFAULT 0,
-		numtxs INTEGER NOT NULL DEFAULT 0,
-		emptyblock INTEGER NOT NULL DEFAULT 0
-	 );`
-	_, err = db.Exec(createTableSQL)
+func openPostgres(dbURL string) (Store, error) {
+	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create or update table: %v", err)
+		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
-
-	return db, nil
+	// sql.Open does not connect, an unreachable server is reported at startup rather than on the first block
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	return newSQLStore(db, postgresDialect), nil
 }
 
-// CloseDB closes the database connection.
-func CloseDB(db *sql.DB) {
+// CloseDB closes the store.
+func CloseDB(db Store) {
 	err := db.Close()
 	if err != nil {
 		logger.PostLog("ERROR", fmt.Sprintf("Error closing the database: %v", err))
@@ -4,41 +4,65 @@ import (
 	"cometbftsignrate/internal/logger"
 	"database/sql"
 	"fmt"
-	"os"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func InsertBlockHeight(db *sql.DB, timestamp string, chainID string, address string, blockHeight int, signatureFound bool, valTimestamp string, signature string, proposerMatch bool, numTXs int, emptyBlock bool) error {
-	// Check the highest block_height for the given chain_id
-	var latestRecordedBlockHeight sql.NullInt64
-	querySQL := `SELECT MAX(block_height) FROM cometbft_signatures WHERE chain_id = ?`
-	err := db.QueryRow(querySQL, chainID).Scan(&latestRecordedBlockHeight)
-	if err != nil && err != sql.ErrNoRows {
-		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chainID, Operation: "latestRecordedBlockHeight", Height: blockHeight, Success: false, Message: err.Error()})
-		return fmt.Errorf("failed to query max block height: %v", err)
-	}
+// BlockRecord is a single row of cometbft_signatures.
+// Timestamp, ProposerMatch, NumTXs and EmptyBlock describe BlockHeight, the signature fields describe
+// the commit for CommitHeight carried in that blocks last_commit.
+type BlockRecord struct {
+	Timestamp          string
+	ChainID            string
+	Address            string
+	BlockHeight        int
+	CommitHeight       int
+	SignatureFound     bool
+	BlockIDFlag        int
+	ValidatorTimestamp string
+	Signature          string
+	ProposerMatch      bool
+	NumTXs             int
+	EmptyBlock         bool
+	VerificationStatus int
+	// InActiveSet is false if the validator was not in the validator set of CommitHeight, e.g. jailed or unbonded
+	InActiveSet bool
+	VotingPower int64
+	// IsolatedMiss is true for a miss while the rest of the network committed the block
+	IsolatedMiss bool
+	// Share of the validators and of the voting power that committed CommitHeight, the power share is NULL if the validator set is unknown
+	NetworkCommittedFraction      float64
+	NetworkCommittedPowerFraction sql.NullFloat64
+}
 
-	// Check if the block_height already exists
-	var exists bool
-	checkSQL := `SELECT EXISTS (SELECT 1 FROM cometbft_signatures WHERE block_height = ?)`
-	err = db.QueryRow(checkSQL, blockHeight).Scan(&exists)
-	if err != nil {
-		logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "BlockHeightExist", Height: blockHeight, Success: false, Message: err.Error()})
-	}
+// InsertBlockHeight stores record, a row already stored for the same chain, address and height is replaced
+func (db *sqlStore) InsertBlockHeight(record BlockRecord) error {
+	chainID := record.ChainID
+	blockHeight := record.BlockHeight
 
-	if !exists {
-		// Insert the new row
-		insertSQL := `INSERT INTO cometbft_signatures (timestamp, chain_id, address, block_height, validatortimestamp,signature, signaturefound, proposermatch, numtxs, emptyblock)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = db.Exec(insertSQL, timestamp, chainID, address, blockHeight, valTimestamp, signature, signatureFound, proposerMatch, numTXs, emptyBlock)
-		if err != nil {
-			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chainID, Operation: "InsertBlock", Height: blockHeight, Success: false, Message: err.Error()})
-			os.Exit(1)
-		}
-		logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "InsertBlock", Height: blockHeight, SignatureFound: signatureFound, Success: true, Message: "Successfully inserted block height into DB"})
-	} else {
-		logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "InsertBlock", Height: blockHeight, Success: false, Message: "Block height already exists in DB"})
+	upsertSQL := `INSERT INTO cometbft_signatures (timestamp, chain_id, address, block_height, commit_height, validatortimestamp,signature, signaturefound, block_id_flag, verification_status, in_active_set, voting_power, isolated_miss, network_committed_fraction, network_committed_power_fraction, proposermatch, numtxs, emptyblock)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chain_id, address, block_height) DO UPDATE SET
+			timestamp = excluded.timestamp,
+			commit_height = excluded.commit_height,
+			validatortimestamp = excluded.validatortimestamp,
+			signature = excluded.signature,
+			signaturefound = excluded.signaturefound,
+			block_id_flag = excluded.block_id_flag,
+			verification_status = excluded.verification_status,
+			in_active_set = excluded.in_active_set,
+			voting_power = excluded.voting_power,
+			isolated_miss = excluded.isolated_miss,
+			network_committed_fraction = excluded.network_committed_fraction,
+			network_committed_power_fraction = excluded.network_committed_power_fraction,
+			proposermatch = excluded.proposermatch,
+			numtxs = excluded.numtxs,
+			emptyblock = excluded.emptyblock`
+	_, err := db.Exec(upsertSQL, record.Timestamp, chainID, record.Address, blockHeight, record.CommitHeight, record.ValidatorTimestamp, record.Signature, record.SignatureFound, record.BlockIDFlag, record.VerificationStatus, record.InActiveSet, record.VotingPower, record.IsolatedMiss, record.NetworkCommittedFraction, record.NetworkCommittedPowerFraction, record.ProposerMatch, record.NumTXs, record.EmptyBlock)
+	if err != nil {
+		logger.PostLog("ERROR", logger.ModuleDB{ChainID: chainID, Operation: "InsertBlock", Height: blockHeight, Success: false, Message: err.Error()})
+		return fmt.Errorf("failed to insert block height: %v", err)
 	}
+	logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "InsertBlock", Height: blockHeight, SignatureFound: record.SignatureFound, Success: true, Message: "Successfully stored block height in DB"})
 	return nil
 }
@@ -0,0 +1,135 @@
+package db_utils
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// KnownGap is a range of heights that could not be fetched, e.g. because the node had already pruned them.
+// Its heights are neither missed nor signed.
+type KnownGap struct {
+	ChainID    string
+	FromHeight int
+	ToHeight   int
+	Reason     string
+	RecordedAt string
+}
+
+func createKnownGapTable(db *sqlStore) error {
+	createTableSQL := `CREATE TABLE IF NOT EXISTS known_gaps (
+		chain_id TEXT NOT NULL,
+		from_height INTEGER NOT NULL,
+		to_height INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		recorded_at TEXT NOT NULL,
+		PRIMARY KEY (chain_id, from_height, to_height)
+	 );`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create known_gaps table: %v", err)
+	}
+	return nil
+}
+
+// InsertKnownGap records [fromHeight, toHeight] as unreachable, a range that is already recorded is left untouched
+func (db *sqlStore) InsertKnownGap(chainID string, fromHeight int, toHeight int, reason string) error {
+	insertSQL := `INSERT INTO known_gaps (chain_id, from_height, to_height, reason, recorded_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`
+	_, err := db.Exec(insertSQL, chainID, fromHeight, toHeight, reason, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert known gap for chain_id %s: %v", chainID, err)
+	}
+	return nil
+}
+
+// GetKnownGaps returns the known gaps of chainID that overlap [fromHeight, toHeight], oldest first.
+// A toHeight of 0 means no upper bound.
+func (db *sqlStore) GetKnownGaps(chainID string, fromHeight int, toHeight int) ([]KnownGap, error) {
+	querySQL := `
+		SELECT chain_id, from_height, to_height, reason, recorded_at
+		FROM known_gaps
+		WHERE chain_id = ? AND to_height >= ? AND (? = 0 OR from_height <= ?)
+		ORDER BY from_height`
+	rows, err := db.Query(querySQL, chainID, fromHeight, toHeight, toHeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get known gaps for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	gaps := []KnownGap{}
+	for rows.Next() {
+		var gap KnownGap
+		if err := rows.Scan(&gap.ChainID, &gap.FromHeight, &gap.ToHeight, &gap.Reason, &gap.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan known gaps for chain_id %s: %v", chainID, err)
+		}
+		gaps = append(gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get known gaps for chain_id %s: %v", chainID, err)
+	}
+	return gaps, nil
+}
+
+// GetKnownGapCount returns the number of heights in [fromHeight, toHeight] that belong to a known gap
+func (db *sqlStore) GetKnownGapCount(chainID string, fromHeight int, toHeight int) (int, error) {
+	gaps, err := db.GetKnownGaps(chainID, fromHeight, toHeight)
+	if err != nil {
+		return 0, err
+	}
+	return countKnownGapHeights(gaps, fromHeight, toHeight), nil
+}
+
+// GetUnreachableCount returns the number of heights in the last window commit heights of address that belong to
+// a known gap, the same heights the signing window queries count
+func (db *sqlStore) GetUnreachableCount(chainID string, address string, window int) (int, error) {
+	var latestCommitHeight sql.NullInt64
+	querySQL := `SELECT MAX(commit_height) FROM cometbft_signatures WHERE chain_id = ? AND address = ?`
+	if err := db.QueryRow(querySQL, chainID, address).Scan(&latestCommitHeight); err != nil {
+		return 0, fmt.Errorf("failed to get latest commit height for chain_id %s: %v", chainID, err)
+	}
+	if !latestCommitHeight.Valid {
+		return 0, nil
+	}
+	fromHeight, toHeight := knownGapWindow(int(latestCommitHeight.Int64), window)
+	return db.GetKnownGapCount(chainID, fromHeight, toHeight)
+}
+
+// knownGapWindow returns the block heights carrying the last window commits up to latestCommitHeight.
+// Known gaps are recorded in block heights, the block at height h carries the commit of h-1.
+func knownGapWindow(latestCommitHeight int, window int) (int, int) {
+	return latestCommitHeight - window + 2, latestCommitHeight + 1
+}
+
+// countKnownGapHeights returns the number of heights in [fromHeight, toHeight] covered by gaps, ordered by from_height
+func countKnownGapHeights(gaps []KnownGap, fromHeight int, toHeight int) int {
+	// gaps may overlap when the same range was recorded with different bounds
+	count, next := 0, fromHeight
+	for _, gap := range gaps {
+		from, to := gap.FromHeight, gap.ToHeight
+		if from < next {
+			from = next
+		}
+		if to > toHeight {
+			to = toHeight
+		}
+		if to >= from {
+			count += to - from + 1
+			next = to + 1
+		}
+	}
+	return count
+}
+
+// DeleteOldKnownGaps deletes the known gaps of chainID that end before the last recordCount heights
+func (db *sqlStore) DeleteOldKnownGaps(chainID string, recordCount int) error {
+	deleteSQL := `
+		DELETE FROM known_gaps
+		WHERE chain_id = ?
+			AND to_height <= (SELECT MAX(block_height) FROM cometbft_signatures WHERE chain_id = ?) - ?`
+	if _, err := db.Exec(deleteSQL, chainID, chainID, recordCount); err != nil {
+		return fmt.Errorf("failed to delete old known gaps for chain_id %s: %v", chainID, err)
+	}
+	return nil
+}
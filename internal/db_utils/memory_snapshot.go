@@ -0,0 +1,118 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// memorySnapshot is the content of a memoryStore written to its snapshot file
+type memorySnapshot struct {
+	Records        []BlockRecord
+	Participation  []participationBlock
+	ValidatorSets  []memoryValidatorSet
+	KnownGaps      []KnownGap
+	Incidents      []Incident
+	Backfills      []BackfillCheckpoint
+	NextIncidentID int64
+}
+
+// openMemory returns a memoryStore restored from snapshotFile, empty if the file does not exist yet
+func openMemory(snapshotFile string, signingWindows map[string]int) (Store, error) {
+	s := newMemoryStore(signingWindows)
+	s.snapshotFile = snapshotFile
+	if snapshotFile == "" {
+		return s, nil
+	}
+
+	file, err := os.Open(snapshotFile)
+	if os.IsNotExist(err) {
+		logger.PostLog("INFO", fmt.Sprintf("No memory snapshot at %s, starting empty", snapshotFile))
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memory snapshot: %v", err)
+	}
+	defer file.Close()
+
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read memory snapshot %s: %v", snapshotFile, err)
+	}
+	s.restore(snapshot)
+	logger.PostLog("INFO", fmt.Sprintf("Restored %d records from the memory snapshot %s", len(snapshot.Records), snapshotFile))
+	return s, nil
+}
+
+// restore replays snapshot into the store. The rings are refilled row by row, a signing window that changed
+// since the snapshot was written only drops the rows that no longer fit.
+func (s *memoryStore) restore(snapshot memorySnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range snapshot.Records {
+		s.insertBlockHeight(record)
+	}
+	for _, block := range snapshot.Participation {
+		s.insertParticipation(block)
+	}
+	for _, set := range snapshot.ValidatorSets {
+		s.insertValidatorSet(set)
+	}
+	for _, gap := range snapshot.KnownGaps {
+		s.insertKnownGap(gap)
+	}
+	for _, incident := range snapshot.Incidents {
+		s.putIncident(incident)
+	}
+	for _, checkpoint := range snapshot.Backfills {
+		s.saveBackfillCheckpoint(checkpoint)
+	}
+	if snapshot.NextIncidentID > s.nextIncidentID {
+		s.nextIncidentID = snapshot.NextIncidentID
+	}
+}
+
+// writeSnapshot writes the store to its snapshot file. The write lock must be held.
+func (s *memoryStore) writeSnapshot() error {
+	snapshot := memorySnapshot{NextIncidentID: s.nextIncidentID}
+	for _, c := range s.chains {
+		for _, r := range c.rings {
+			// oldest first, so a smaller window on restore keeps the newest rows
+			for height := r.head - len(r.slots) + 1; height <= r.head; height++ {
+				if record, ok := r.get(height); ok {
+					snapshot.Records = append(snapshot.Records, record)
+				}
+			}
+		}
+		snapshot.Participation = append(snapshot.Participation, c.participationBlocks()...)
+		snapshot.ValidatorSets = append(snapshot.ValidatorSets, c.validatorSets...)
+		snapshot.KnownGaps = append(snapshot.KnownGaps, c.knownGaps...)
+		snapshot.Incidents = append(snapshot.Incidents, c.incidents...)
+		for _, checkpoint := range c.backfills {
+			snapshot.Backfills = append(snapshot.Backfills, checkpoint)
+		}
+	}
+
+	// written next to the snapshot and renamed, a crash while writing leaves the previous snapshot intact
+	file, err := os.CreateTemp(filepath.Dir(s.snapshotFile), filepath.Base(s.snapshotFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %v", err)
+	}
+	if err := gob.NewEncoder(file).Encode(snapshot); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write memory snapshot: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write memory snapshot: %v", err)
+	}
+	if err := os.Rename(file.Name(), s.snapshotFile); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write memory snapshot: %v", err)
+	}
+	logger.PostLog("INFO", fmt.Sprintf("Wrote %d records to the memory snapshot %s", len(snapshot.Records), s.snapshotFile))
+	return nil
+}
@@ -0,0 +1,1172 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultMemoryWindow is the number of heights a memoryStore keeps for a chain without a signing_window
+const defaultMemoryWindow = 10000
+
+// memoryStore is the Store of stateless deployments, it keeps the last signing window of every chain in memory.
+// Rows older than the window are dropped as new heights arrive, so the store behaves like a SQL store with pruning enabled.
+// The incidents, known gaps and validator sets that ended before the window are dropped as well.
+type memoryStore struct {
+	mu             sync.RWMutex
+	windows        map[string]int
+	chains         map[string]*memoryChain
+	nextIncidentID int64
+	// written on Close and restored by openMemory, nothing is persisted if it is empty
+	snapshotFile string
+	closed       bool
+}
+
+func newMemoryStore(signingWindows map[string]int) *memoryStore {
+	return &memoryStore{
+		windows:        signingWindows,
+		chains:         make(map[string]*memoryChain),
+		nextIncidentID: 1,
+	}
+}
+
+// memoryChain is everything a memoryStore holds for one chain
+type memoryChain struct {
+	window int
+	rings  map[string]*signatureRing
+	// slot of a height is height % window, like the slots of a signatureRing
+	participation     []participationBlock
+	participationHead int
+	// ascending height
+	validatorSets []memoryValidatorSet
+	// ascending from_height
+	knownGaps []KnownGap
+	incidents []Incident
+	backfills map[HeightRange]BackfillCheckpoint
+}
+
+// participationBlock is the participation stored for one block
+type participationBlock struct {
+	ChainID      string
+	BlockHeight  int
+	CommitHeight int
+	Records      []ParticipationRecord
+}
+
+// memoryValidatorSet is a validator set snapshot
+type memoryValidatorSet struct {
+	ChainID        string
+	Height         int
+	Timestamp      string
+	ValidatorsHash string
+	Members        []ValidatorSetMember
+}
+
+// chain returns the state of chainID, created with the signing window of the chain on first use. The write lock must be held.
+func (s *memoryStore) chain(chainID string) *memoryChain {
+	if c, ok := s.chains[chainID]; ok {
+		return c
+	}
+	window := s.windows[chainID]
+	if window <= 0 {
+		window = defaultMemoryWindow
+	}
+	c := &memoryChain{
+		window:        window,
+		rings:         make(map[string]*signatureRing),
+		participation: make([]participationBlock, window),
+		backfills:     make(map[HeightRange]BackfillCheckpoint),
+	}
+	s.chains[chainID] = c
+	return c
+}
+
+// ring returns the ring of address, nil if the chain or the validator has no rows. The read lock must be held.
+func (s *memoryStore) ring(chainID string, address string) *signatureRing {
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil
+	}
+	return c.rings[address]
+}
+
+// head returns the ring holding the highest height of the chain, nil if it has no rows
+func (c *memoryChain) head() *signatureRing {
+	var head *signatureRing
+	for _, r := range c.rings {
+		if r.counts.records > 0 && (head == nil || r.blockHead > head.blockHead) {
+			head = r
+		}
+	}
+	return head
+}
+
+// headHeight returns the highest block height stored for the chain, 0 if there is none
+func (c *memoryChain) headHeight() int {
+	if head := c.head(); head != nil {
+		return head.blockHead
+	}
+	return 0
+}
+
+// participationBlocks returns the participation stored within the window, a slot that was not reused since the head
+// moved past it still holds an older block
+func (c *memoryChain) participationBlocks() []participationBlock {
+	var blocks []participationBlock
+	for _, block := range c.participation {
+		if block.BlockHeight != 0 && block.BlockHeight > c.participationHead-c.window {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// signatureCounts are the counts of a signing window, see add for the rows each one counts
+type signatureCounts struct {
+	records       int
+	active        int
+	missed        int
+	committed     int
+	nilVoted      int
+	absent        int
+	invalid       int
+	outOfSet      int
+	isolated      int
+	networkWide   int
+	proposed      int
+	emptyProposed int
+}
+
+// add adds n times record to the counts, n is -1 when a row is replaced or dropped
+func (c *signatureCounts) add(record BlockRecord, n int) {
+	c.records += n
+	if record.VerificationStatus == 2 {
+		c.invalid += n
+	}
+	if record.ProposerMatch {
+		c.proposed += n
+		if record.NumTXs == 0 {
+			c.emptyProposed += n
+		}
+	}
+	if !record.InActiveSet {
+		c.outOfSet += n
+		return
+	}
+	c.active += n
+	switch record.BlockIDFlag {
+	case 2:
+		c.committed += n
+	case 3:
+		c.nilVoted += n
+	default:
+		c.absent += n
+	}
+	if !record.SignatureFound {
+		c.missed += n
+		if record.IsolatedMiss {
+			c.isolated += n
+		} else {
+			c.networkWide += n
+		}
+	}
+}
+
+// signatureRing holds the rows of one validator for the commit heights (head - len(slots), head], the heights the
+// signing window queries of the SQL stores count. The counts of every stored row are updated on each write, a query
+// over the whole window does not scan the rows. Rows are unique per block height like in the SQL stores, blocks maps
+// the block height of every stored row to its commit height for the lookups by block height.
+type signatureRing struct {
+	// slot of a commit height is height % len(slots), BlockHeight is 0 if the slot is empty
+	slots []BlockRecord
+	// highest commit height, -1 while the ring is empty
+	head      int
+	blocks    map[int]int
+	blockHead int
+	counts    signatureCounts
+}
+
+func newSignatureRing(window int) *signatureRing {
+	return &signatureRing{slots: make([]BlockRecord, window), head: -1, blocks: make(map[int]int)}
+}
+
+// insert stores record, replacing the row of the same block height. A commit height below the window is dropped,
+// a second block carrying the same commit height replaces the first.
+func (r *signatureRing) insert(record BlockRecord) {
+	height := record.CommitHeight
+	if record.BlockHeight <= 0 || height < 0 || height <= r.head-len(r.slots) {
+		return
+	}
+	if stored, ok := r.blocks[record.BlockHeight]; ok {
+		r.remove(&r.slots[stored%len(r.slots)])
+	}
+	if height > r.head {
+		r.evict(height - len(r.slots))
+		r.head = height
+	}
+	slot := &r.slots[height%len(r.slots)]
+	if slot.BlockHeight != 0 {
+		r.remove(slot)
+	}
+	*slot = record
+	r.blocks[record.BlockHeight] = height
+	r.counts.add(record, 1)
+	if record.BlockHeight > r.blockHead {
+		r.blockHead = record.BlockHeight
+	}
+	r.updateBlockHead()
+}
+
+// remove empties slot
+func (r *signatureRing) remove(slot *BlockRecord) {
+	r.counts.add(*slot, -1)
+	delete(r.blocks, slot.BlockHeight)
+	*slot = BlockRecord{}
+}
+
+// updateBlockHead moves blockHead down to the highest stored block height once its row was removed
+func (r *signatureRing) updateBlockHead() {
+	if _, ok := r.blocks[r.blockHead]; ok || r.blockHead == 0 {
+		return
+	}
+	r.blockHead = 0
+	for block := range r.blocks {
+		if block > r.blockHead {
+			r.blockHead = block
+		}
+	}
+}
+
+// evict drops every row at or below the commit height
+func (r *signatureRing) evict(height int) {
+	if height >= r.head {
+		r.slots = make([]BlockRecord, len(r.slots))
+		r.blocks = make(map[int]int)
+		r.counts = signatureCounts{}
+		r.head = -1
+		r.blockHead = 0
+		return
+	}
+	for h := max(r.head-len(r.slots)+1, 0); h <= height; h++ {
+		slot := &r.slots[h%len(r.slots)]
+		if slot.BlockHeight != 0 && slot.CommitHeight == h {
+			r.remove(slot)
+		}
+	}
+	r.updateBlockHead()
+}
+
+// evictBlocks drops every row at or below the block height, the SQL stores prune by block height
+func (r *signatureRing) evictBlocks(blockHeight int) {
+	for block, height := range r.blocks {
+		if block <= blockHeight {
+			r.remove(&r.slots[height%len(r.slots)])
+		}
+	}
+	if r.counts.records == 0 {
+		r.evict(r.head)
+		return
+	}
+	r.updateBlockHead()
+}
+
+// get returns the row of the commit height, false if it is not stored
+func (r *signatureRing) get(height int) (BlockRecord, bool) {
+	if height < 0 || height > r.head || height <= r.head-len(r.slots) {
+		return BlockRecord{}, false
+	}
+	record := r.slots[height%len(r.slots)]
+	return record, record.BlockHeight != 0 && record.CommitHeight == height
+}
+
+// getBlock returns the row of the block height, false if it is not stored
+func (r *signatureRing) getBlock(blockHeight int) (BlockRecord, bool) {
+	height, ok := r.blocks[blockHeight]
+	if !ok {
+		return BlockRecord{}, false
+	}
+	return r.get(height)
+}
+
+// window returns the counts of the last window commit heights. Only a window shorter than the ring is counted row by row.
+func (r *signatureRing) window(window int) signatureCounts {
+	if window >= len(r.slots) {
+		return r.counts
+	}
+	var counts signatureCounts
+	for height := r.head - window + 1; height <= r.head; height++ {
+		if record, ok := r.get(height); ok {
+			counts.add(record, 1)
+		}
+	}
+	return counts
+}
+
+// latest returns up to n rows, newest first. Commit heights increase with block heights, so this is the block height
+// order of the SQL stores as well.
+func (r *signatureRing) latest(n int) []BlockRecord {
+	var records []BlockRecord
+	for height := r.head; height > r.head-len(r.slots) && len(records) < n; height-- {
+		if record, ok := r.get(height); ok {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// lowest returns the lowest block height stored, 0 if there is none
+func (r *signatureRing) lowest() int {
+	lowest := 0
+	for block := range r.blocks {
+		if lowest == 0 || block < lowest {
+			lowest = block
+		}
+	}
+	return lowest
+}
+
+// the writes of the BlockWriter methods, shared by the store and its transactions. The write lock must be held.
+
+func (s *memoryStore) insertBlockHeight(record BlockRecord) {
+	c := s.chain(record.ChainID)
+	r, ok := c.rings[record.Address]
+	if !ok {
+		r = newSignatureRing(c.window)
+		c.rings[record.Address] = r
+	}
+	r.insert(record)
+}
+
+func (s *memoryStore) insertParticipation(block participationBlock) {
+	c := s.chain(block.ChainID)
+	if block.BlockHeight <= 0 || block.BlockHeight <= c.participationHead-c.window {
+		return
+	}
+	if block.BlockHeight > c.participationHead {
+		c.participationHead = block.BlockHeight
+	}
+	slot := &c.participation[block.BlockHeight%c.window]
+	if slot.BlockHeight != block.BlockHeight {
+		*slot = participationBlock{ChainID: block.ChainID, BlockHeight: block.BlockHeight, CommitHeight: block.CommitHeight}
+	}
+	// like the unique index of the SQL stores, the vote already stored for a validator is kept
+	for _, record := range block.Records {
+		stored := false
+		for _, existing := range slot.Records {
+			if existing.Address == record.Address {
+				stored = true
+				break
+			}
+		}
+		if !stored {
+			slot.Records = append(slot.Records, record)
+		}
+	}
+}
+
+func (s *memoryStore) insertValidatorSet(set memoryValidatorSet) {
+	c := s.chain(set.ChainID)
+	i := sort.Search(len(c.validatorSets), func(i int) bool { return c.validatorSets[i].Height >= set.Height })
+	if i == len(c.validatorSets) || c.validatorSets[i].Height != set.Height {
+		c.validatorSets = append(c.validatorSets, memoryValidatorSet{})
+		copy(c.validatorSets[i+1:], c.validatorSets[i:])
+		c.validatorSets[i] = memoryValidatorSet{ChainID: set.ChainID, Height: set.Height, Timestamp: set.Timestamp, ValidatorsHash: set.ValidatorsHash}
+	}
+	stored := &c.validatorSets[i]
+	for _, member := range set.Members {
+		known := false
+		for _, existing := range stored.Members {
+			if existing.Address == member.Address {
+				known = true
+				break
+			}
+		}
+		if !known {
+			stored.Members = append(stored.Members, member)
+		}
+	}
+	c.deleteOldValidatorSets(c.headHeight() - c.window)
+}
+
+// putIncident stores incident, replacing the incident with the same id
+func (s *memoryStore) putIncident(incident Incident) {
+	c := s.chain(incident.ChainID)
+	for i := range c.incidents {
+		if c.incidents[i].ID == incident.ID {
+			c.incidents[i] = incident
+			return
+		}
+	}
+	c.incidents = append(c.incidents, incident)
+	if incident.ID >= s.nextIncidentID {
+		s.nextIncidentID = incident.ID + 1
+	}
+
+	// resolved incidents that ended before the window are no longer shown
+	cutoff := c.headHeight() - c.window
+	kept := c.incidents[:0]
+	for _, stored := range c.incidents {
+		if stored.Status == IncidentOpen || stored.EndHeight > cutoff {
+			kept = append(kept, stored)
+		}
+	}
+	c.incidents = kept
+}
+
+func (s *memoryStore) updateIncident(incident Incident) {
+	c := s.chain(incident.ChainID)
+	for i := range c.incidents {
+		if c.incidents[i].ID == incident.ID {
+			c.incidents[i] = applyIncidentUpdate(c.incidents[i], incident)
+			return
+		}
+	}
+}
+
+// applyIncidentUpdate returns stored with the fields UpdateIncident writes taken from update
+func applyIncidentUpdate(stored Incident, update Incident) Incident {
+	stored.EndHeight = update.EndHeight
+	stored.EndTime = update.EndTime
+	stored.BlockCount = update.BlockCount
+	stored.Status = update.Status
+	stored.ResolvedHeight = update.ResolvedHeight
+	return stored
+}
+
+func (s *memoryStore) saveBackfillCheckpoint(checkpoint BackfillCheckpoint) {
+	c := s.chain(checkpoint.ChainID)
+	c.backfills[HeightRange{From: checkpoint.FromHeight, To: checkpoint.ToHeight}] = checkpoint
+}
+
+func (s *memoryStore) insertKnownGap(gap KnownGap) {
+	c := s.chain(gap.ChainID)
+	for _, stored := range c.knownGaps {
+		if stored.FromHeight == gap.FromHeight && stored.ToHeight == gap.ToHeight {
+			return
+		}
+	}
+	i := sort.Search(len(c.knownGaps), func(i int) bool { return c.knownGaps[i].FromHeight > gap.FromHeight })
+	c.knownGaps = append(c.knownGaps, KnownGap{})
+	copy(c.knownGaps[i+1:], c.knownGaps[i:])
+	c.knownGaps[i] = gap
+	c.deleteOldKnownGaps(c.headHeight() - c.window)
+}
+
+// deleteOldValidatorSets deletes the snapshots below cutoff except the newest of them
+func (c *memoryChain) deleteOldValidatorSets(cutoff int) {
+	newest := -1
+	for i, set := range c.validatorSets {
+		if set.Height <= cutoff {
+			newest = i
+		}
+	}
+	if newest > 0 {
+		c.validatorSets = append(c.validatorSets[:0], c.validatorSets[newest:]...)
+	}
+}
+
+// deleteOldKnownGaps deletes the known gaps ending at or below cutoff
+func (c *memoryChain) deleteOldKnownGaps(cutoff int) {
+	kept := c.knownGaps[:0]
+	for _, gap := range c.knownGaps {
+		if gap.ToHeight > cutoff {
+			kept = append(kept, gap)
+		}
+	}
+	c.knownGaps = kept
+}
+
+// validatorSetAt returns the latest snapshot at or below height, false if there is none
+func (s *memoryStore) validatorSetAt(chainID string, height int) (memoryValidatorSet, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return memoryValidatorSet{}, false
+	}
+	for i := len(c.validatorSets) - 1; i >= 0; i-- {
+		if c.validatorSets[i].Height <= height {
+			return c.validatorSets[i], true
+		}
+	}
+	return memoryValidatorSet{}, false
+}
+
+// incidentsOf returns a copy of the incidents of address
+func (s *memoryStore) incidentsOf(chainID string, address string) []Incident {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil
+	}
+	var incidents []Incident
+	for _, incident := range c.incidents {
+		if incident.Address == address {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents
+}
+
+// openIncident returns the open incident with the highest start height
+func openIncident(incidents []Incident) (Incident, bool) {
+	var open Incident
+	found := false
+	for _, incident := range incidents {
+		if incident.Status == IncidentOpen && (!found || incident.StartHeight > open.StartHeight) {
+			open = incident
+			found = true
+		}
+	}
+	return open, found
+}
+
+func (s *memoryStore) allocateIncidentID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextIncidentID
+	s.nextIncidentID++
+	return id
+}
+
+func logInsertBlock(record BlockRecord) {
+	logger.PostLog("INFO", logger.ModuleDB{ChainID: record.ChainID, Operation: "InsertBlock", Height: record.BlockHeight, SignatureFound: record.SignatureFound, Success: true, Message: "Successfully stored block height in memory"})
+}
+
+func logInsertValidatorSet(set memoryValidatorSet) {
+	logger.PostLog("INFO", logger.ModuleDB{ChainID: set.ChainID, Operation: "InsertValidatorSetSnapshot", Height: set.Height, Success: true, Message: fmt.Sprintf("Stored validator set %s with %d validators", set.ValidatorsHash, len(set.Members))})
+}
+
+// BlockWriter
+
+func (s *memoryStore) InsertBlockHeight(record BlockRecord) error {
+	s.mu.Lock()
+	s.insertBlockHeight(record)
+	s.mu.Unlock()
+	logInsertBlock(record)
+	return nil
+}
+
+func (s *memoryStore) InsertParticipation(chainID string, blockHeight int, commitHeight int, records []ParticipationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insertParticipation(participationBlock{ChainID: chainID, BlockHeight: blockHeight, CommitHeight: commitHeight, Records: records})
+	return nil
+}
+
+func (s *memoryStore) InsertValidatorSetSnapshot(chainID string, height int, timestamp string, validatorsHash string, members []ValidatorSetMember) error {
+	set := memoryValidatorSet{ChainID: chainID, Height: height, Timestamp: timestamp, ValidatorsHash: validatorsHash, Members: members}
+	s.mu.Lock()
+	s.insertValidatorSet(set)
+	s.mu.Unlock()
+	logInsertValidatorSet(set)
+	return nil
+}
+
+func (s *memoryStore) GetValidatorSetHashAt(chainID string, height int) (string, error) {
+	set, _ := s.validatorSetAt(chainID, height)
+	return set.ValidatorsHash, nil
+}
+
+func (s *memoryStore) GetOpenIncident(chainID string, address string) (Incident, bool, error) {
+	incident, ok := openIncident(s.incidentsOf(chainID, address))
+	return incident, ok, nil
+}
+
+func (s *memoryStore) InsertIncident(incident Incident) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incident.ID = s.nextIncidentID
+	s.putIncident(incident)
+	return incident.ID, nil
+}
+
+func (s *memoryStore) UpdateIncident(incident Incident) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateIncident(incident)
+	return nil
+}
+
+func (s *memoryStore) SaveBackfillCheckpoint(checkpoint BackfillCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveBackfillCheckpoint(checkpoint)
+	return nil
+}
+
+// Begin starts a transaction, its writes are applied on Commit
+func (s *memoryStore) Begin() (Tx, error) {
+	return &memoryTx{store: s, incidents: make(map[int64]Incident)}, nil
+}
+
+// Close writes the snapshot file if one is configured
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.snapshotFile == "" {
+		return nil
+	}
+	return s.writeSnapshot()
+}
+
+// Ingestion progress
+
+// GetLastBlockHeight returns the highest stored height of the chain. Heights below the window of the chain can not
+// be kept, the height is moved up to the start of the window whether pruning is enabled or not.
+func (s *memoryStore) GetLastBlockHeight(chainID string, currentNodeHeight int, signingWindow int, pruningEnabled bool) (int, error) {
+	s.mu.RLock()
+	c, ok := s.chains[chainID]
+	blockHeight := 0
+	window := signingWindow
+	if ok {
+		blockHeight = c.headHeight()
+		if !pruningEnabled || c.window < window {
+			window = c.window
+		}
+	}
+	s.mu.RUnlock()
+
+	if blockHeight == 0 {
+		return 0, nil
+	}
+	if currentNodeHeight-blockHeight > window {
+		logger.PostLog("WARN", fmt.Sprintf("Last checked height for %s is older than the signing window (%d)", chainID, window))
+		return currentNodeHeight - window, nil
+	}
+	return blockHeight, nil
+}
+
+func (s *memoryStore) GetStoredHeightRange(chainID string) (int, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return 0, 0, nil
+	}
+	minHeight, maxHeight := 0, 0
+	for _, r := range c.rings {
+		if r.counts.records == 0 {
+			continue
+		}
+		if lowest := r.lowest(); minHeight == 0 || lowest < minHeight {
+			minHeight = lowest
+		}
+		if r.blockHead > maxHeight {
+			maxHeight = r.blockHead
+		}
+	}
+	return minHeight, maxHeight, nil
+}
+
+func (s *memoryStore) GetLatestStoredHeight(chainID string, address string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r := s.ring(chainID, address); r != nil {
+		return r.blockHead, nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) GetMissingHeights(chainID string, address string, fromHeight int, toHeight int) ([]HeightRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := s.ring(chainID, address)
+	var gaps []HeightRange
+	for height := fromHeight; height <= toHeight; height++ {
+		if r != nil {
+			if _, ok := r.getBlock(height); ok {
+				continue
+			}
+		}
+		if len(gaps) > 0 && gaps[len(gaps)-1].To == height-1 {
+			gaps[len(gaps)-1].To = height
+			continue
+		}
+		gaps = append(gaps, HeightRange{From: height, To: height})
+	}
+	return gaps, nil
+}
+
+func (s *memoryStore) GetBackfillCheckpoint(chainID string, fromHeight int, toHeight int) (BackfillCheckpoint, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return BackfillCheckpoint{}, false, nil
+	}
+	checkpoint, ok := c.backfills[HeightRange{From: fromHeight, To: toHeight}]
+	return checkpoint, ok, nil
+}
+
+func (s *memoryStore) GetPendingBackfills(chainID string) ([]BackfillCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil, nil
+	}
+	var checkpoints []BackfillCheckpoint
+	for _, checkpoint := range c.backfills {
+		if !checkpoint.Done() {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].FromHeight < checkpoints[j].FromHeight })
+	return checkpoints, nil
+}
+
+func (s *memoryStore) InsertKnownGap(chainID string, fromHeight int, toHeight int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insertKnownGap(KnownGap{ChainID: chainID, FromHeight: fromHeight, ToHeight: toHeight, Reason: reason, RecordedAt: time.Now().UTC().Format(time.RFC3339)})
+	return nil
+}
+
+func (s *memoryStore) GetKnownGaps(chainID string, fromHeight int, toHeight int) ([]KnownGap, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	gaps := []KnownGap{}
+	c, ok := s.chains[chainID]
+	if !ok {
+		return gaps, nil
+	}
+	for _, gap := range c.knownGaps {
+		if gap.ToHeight >= fromHeight && (toHeight == 0 || gap.FromHeight <= toHeight) {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
+
+func (s *memoryStore) GetKnownGapCount(chainID string, fromHeight int, toHeight int) (int, error) {
+	gaps, err := s.GetKnownGaps(chainID, fromHeight, toHeight)
+	if err != nil {
+		return 0, err
+	}
+	return countKnownGapHeights(gaps, fromHeight, toHeight), nil
+}
+
+func (s *memoryStore) GetUnreachableCount(chainID string, address string, window int) (int, error) {
+	s.mu.RLock()
+	latestCommitHeight := -1
+	if r := s.ring(chainID, address); r != nil {
+		latestCommitHeight = r.head
+	}
+	s.mu.RUnlock()
+	if latestCommitHeight < 0 {
+		return 0, nil
+	}
+	fromHeight, toHeight := knownGapWindow(latestCommitHeight, window)
+	return s.GetKnownGapCount(chainID, fromHeight, toHeight)
+}
+
+// Signing window queries, the window of every query is counted in commit heights up to the newest row of the validator
+
+// windowCounts returns the counts of the last window heights of address, the zero counts if it has no rows
+func (s *memoryStore) windowCounts(chainID string, address string, window int) signatureCounts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r := s.ring(chainID, address); r != nil {
+		return r.window(window)
+	}
+	return signatureCounts{}
+}
+
+func (s *memoryStore) GetAmountOfSignatureNotFound(chainID string, address string, numRecords int) (int, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok || c.head() == nil {
+		return 0, "", fmt.Errorf("chain_id %s not found", chainID)
+	}
+	count := 0
+	if r := c.rings[address]; r != nil {
+		count = r.window(numRecords).missed
+	}
+	// the timestamp is the one of the newest block of the chain, any validator may hold it
+	head := c.head()
+	latest, _ := head.getBlock(head.blockHead)
+	return count, latest.Timestamp, nil
+}
+
+// GetTimestampDiff averages the diff over the newest 25 rows the validator signed, a miss has no validator timestamp
+func (s *memoryStore) GetTimestampDiff(chainID string, address string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := s.ring(chainID, address)
+	if r == nil {
+		return 0, nil
+	}
+	timeDiffArray := []int{}
+	for height := r.head; height > r.head-len(r.slots) && len(timeDiffArray) < 25; height-- {
+		record, ok := r.get(height)
+		if !ok || record.ValidatorTimestamp == "" {
+			continue
+		}
+		diff, err := timestampDiff(record.Timestamp, record.ValidatorTimestamp)
+		if err != nil {
+			logger.PostLog("ERROR", err.Error())
+			continue
+		}
+		timeDiffArray = append(timeDiffArray, diff)
+	}
+	return averageDiff(timeDiffArray), nil
+}
+
+func (s *memoryStore) GetNumberOfRecordsForChain(chainID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	if c, ok := s.chains[chainID]; ok {
+		for _, r := range c.rings {
+			count += r.counts.records
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) GetNumberOfRecordsForValidator(chainID string, address string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r := s.ring(chainID, address); r != nil {
+		return r.counts.records, nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) GetNumberOfProposedBlocks(chainID string, address string, window int) (int, error) {
+	return s.windowCounts(chainID, address, window).proposed, nil
+}
+
+func (s *memoryStore) GetNumberOfEmptyProposedBlocks(chainID string, address string, window int) (int, error) {
+	return s.windowCounts(chainID, address, window).emptyProposed, nil
+}
+
+func (s *memoryStore) GetBlockIDFlagCounts(chainID string, address string, window int) (int, int, int, error) {
+	counts := s.windowCounts(chainID, address, window)
+	return counts.committed, counts.nilVoted, counts.absent, nil
+}
+
+func (s *memoryStore) GetInvalidSignatureCount(chainID string, address string, window int) (int, error) {
+	return s.windowCounts(chainID, address, window).invalid, nil
+}
+
+func (s *memoryStore) GetOutOfActiveSetCount(chainID string, address string, window int) (int, error) {
+	return s.windowCounts(chainID, address, window).outOfSet, nil
+}
+
+func (s *memoryStore) GetLatestActiveSetStatus(chainID string, address string) (bool, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r := s.ring(chainID, address); r != nil {
+		if record, ok := r.getBlock(r.blockHead); ok {
+			return record.InActiveSet, record.VotingPower, nil
+		}
+	}
+	return false, 0, nil
+}
+
+func (s *memoryStore) GetMissClassification(chainID string, address string, window int) (int, int, error) {
+	counts := s.windowCounts(chainID, address, window)
+	return counts.isolated, counts.networkWide, nil
+}
+
+func (s *memoryStore) GetCurrentConsecutiveMisses(chainID string, address string) (int, error) {
+	incident, _ := openIncident(s.incidentsOf(chainID, address))
+	return incident.BlockCount, nil
+}
+
+func (s *memoryStore) GetAbsentCount(chainID string, address string, window int) (int, int, error) {
+	counts := s.windowCounts(chainID, address, window)
+	return counts.absent, counts.active, nil
+}
+
+func (s *memoryStore) GetAverageBlockTime(chainID string, address string, samples int) (float64, error) {
+	s.mu.RLock()
+	r := s.ring(chainID, address)
+	var latest, oldest BlockRecord
+	if r != nil && r.counts.records > 0 {
+		// the oldest stored height is the last one when fewer than samples are stored
+		records := r.latest(max(samples, 1))
+		latest, oldest = records[0], records[len(records)-1]
+	}
+	s.mu.RUnlock()
+
+	if latest.BlockHeight <= oldest.BlockHeight {
+		return 0, nil
+	}
+	latestTime, err := time.Parse(time.RFC3339, latest.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block timestamp: %v", err)
+	}
+	oldestTime, err := time.Parse(time.RFC3339, oldest.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block timestamp: %v", err)
+	}
+	return latestTime.Sub(oldestTime).Seconds() / float64(latest.BlockHeight-oldest.BlockHeight), nil
+}
+
+func (s *memoryStore) GetSlashingWindowMisses(chainID string, address string, fromHeight int, toHeight int, window int) (int, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := s.ring(chainID, address)
+	if r == nil {
+		return 0, 0, nil
+	}
+	// newest first like the SQL stores, commit heights increase with block heights
+	var counts signatureCounts
+	for height := r.head; height > r.head-len(r.slots) && counts.active < window; height-- {
+		record, ok := r.get(height)
+		if ok && record.BlockHeight >= fromHeight && record.BlockHeight <= toHeight && record.InActiveSet {
+			counts.add(record, 1)
+		}
+	}
+	return counts.absent, counts.active, nil
+}
+
+func (s *memoryStore) GetLeaderboard(chainID string, window int) ([]LeaderboardEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil, nil
+	}
+	blocks := c.participationBlocks()
+	maxCommit := 0
+	for _, block := range blocks {
+		if block.CommitHeight > maxCommit {
+			maxCommit = block.CommitHeight
+		}
+	}
+	byAddress := make(map[string]*LeaderboardEntry)
+	for _, block := range blocks {
+		if block.CommitHeight <= maxCommit-window {
+			continue
+		}
+		for _, record := range block.Records {
+			entry, ok := byAddress[record.Address]
+			if !ok {
+				entry = &LeaderboardEntry{Address: record.Address}
+				byAddress[record.Address] = entry
+			}
+			entry.Blocks++
+			switch record.BlockIDFlag {
+			case 2:
+				entry.Committed++
+			case 3:
+				entry.NilVoted++
+			default:
+				entry.Absent++
+			}
+			if record.Proposed {
+				entry.Proposed++
+			}
+		}
+	}
+	var entries []LeaderboardEntry
+	for _, entry := range byAddress {
+		entry.SigningRate = float64(entry.Committed) / float64(entry.Blocks)
+		entries = append(entries, *entry)
+	}
+	sortLeaderboard(entries)
+	return entries, nil
+}
+
+func (s *memoryStore) GetIncidents(chainID string, address string, from string, to string, status string) ([]Incident, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	incidents := []Incident{}
+	c, ok := s.chains[chainID]
+	if !ok {
+		return incidents, nil
+	}
+	for _, incident := range c.incidents {
+		if (address == "" || incident.Address == address) &&
+			(from == "" || incident.EndTime >= from) &&
+			(to == "" || incident.StartTime <= to) &&
+			(status == "" || incident.Status == status) {
+			incidents = append(incidents, incident)
+		}
+	}
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].StartHeight > incidents[j].StartHeight })
+	return incidents, nil
+}
+
+func (s *memoryStore) GetVotingPowerHistory(chainID string, address string, fromHeight int, toHeight int) ([]VotingPowerSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil, nil
+	}
+	var history []VotingPowerSnapshot
+	for _, set := range c.validatorSets {
+		if set.Height < fromHeight || (toHeight != 0 && set.Height > toHeight) {
+			continue
+		}
+		snapshot := VotingPowerSnapshot{Height: set.Height, Timestamp: set.Timestamp, ValidatorsHash: set.ValidatorsHash, NumValidators: len(set.Members)}
+		for _, member := range set.Members {
+			snapshot.TotalVotingPower += member.VotingPower
+			if member.Address == address {
+				snapshot.InActiveSet = true
+				snapshot.VotingPower = member.VotingPower
+				snapshot.Rank = member.Rank
+			}
+		}
+		history = append(history, snapshot)
+	}
+	return history, nil
+}
+
+// Pruning, the store drops rows below the window by itself but follows a shorter recordCount
+
+func (s *memoryStore) DeleteOldRecords(chainID string, recordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chains[chainID]
+	if !ok || c.head() == nil {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "DeleteOldRecords", Success: true, Message: "No records to prune"})
+		return nil
+	}
+	cutoff := c.headHeight() - recordCount
+	for _, r := range c.rings {
+		r.evictBlocks(cutoff)
+	}
+	logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "DeleteOldRecords", Success: true, Message: "Successfully deleted old records"})
+	return nil
+}
+
+func (s *memoryStore) DeleteOldValidatorSets(chainID string, recordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.chains[chainID]; ok && c.head() != nil {
+		c.deleteOldValidatorSets(c.headHeight() - recordCount)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteOldKnownGaps(chainID string, recordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.chains[chainID]; ok && c.head() != nil {
+		c.deleteOldKnownGaps(c.headHeight() - recordCount)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteOldParticipation(chainID string, recordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chains[chainID]
+	if !ok {
+		return nil
+	}
+	for i, block := range c.participation {
+		if block.BlockHeight != 0 && block.BlockHeight <= c.participationHead-recordCount {
+			c.participation[i] = participationBlock{}
+		}
+	}
+	return nil
+}
+
+// memoryTx buffers the writes of a transaction of a memoryStore. Its reads see the store and the buffered writes.
+type memoryTx struct {
+	store  *memoryStore
+	writes []func(s *memoryStore)
+	// buffered snapshots and incidents, for the reads of the transaction
+	validatorSets []memoryValidatorSet
+	incidents     map[int64]Incident
+}
+
+func (t *memoryTx) InsertBlockHeight(record BlockRecord) error {
+	t.writes = append(t.writes, func(s *memoryStore) { s.insertBlockHeight(record) })
+	logInsertBlock(record)
+	return nil
+}
+
+func (t *memoryTx) InsertParticipation(chainID string, blockHeight int, commitHeight int, records []ParticipationRecord) error {
+	block := participationBlock{ChainID: chainID, BlockHeight: blockHeight, CommitHeight: commitHeight, Records: records}
+	t.writes = append(t.writes, func(s *memoryStore) { s.insertParticipation(block) })
+	return nil
+}
+
+func (t *memoryTx) InsertValidatorSetSnapshot(chainID string, height int, timestamp string, validatorsHash string, members []ValidatorSetMember) error {
+	set := memoryValidatorSet{ChainID: chainID, Height: height, Timestamp: timestamp, ValidatorsHash: validatorsHash, Members: members}
+	t.writes = append(t.writes, func(s *memoryStore) { s.insertValidatorSet(set) })
+	t.validatorSets = append(t.validatorSets, set)
+	logInsertValidatorSet(set)
+	return nil
+}
+
+func (t *memoryTx) GetValidatorSetHashAt(chainID string, height int) (string, error) {
+	latest, found := t.store.validatorSetAt(chainID, height)
+	for _, set := range t.validatorSets {
+		if set.ChainID == chainID && set.Height <= height && (!found || set.Height > latest.Height) {
+			latest, found = set, true
+		}
+	}
+	return latest.ValidatorsHash, nil
+}
+
+func (t *memoryTx) GetOpenIncident(chainID string, address string) (Incident, bool, error) {
+	var incidents []Incident
+	for _, incident := range t.store.incidentsOf(chainID, address) {
+		if _, ok := t.incidents[incident.ID]; !ok {
+			incidents = append(incidents, incident)
+		}
+	}
+	for _, buffered := range t.incidents {
+		if buffered.ChainID == chainID && buffered.Address == address {
+			incidents = append(incidents, buffered)
+		}
+	}
+	incident, ok := openIncident(incidents)
+	return incident, ok, nil
+}
+
+// InsertIncident takes the id of the incident right away, the ids of a rolled back transaction are not reused
+func (t *memoryTx) InsertIncident(incident Incident) (int64, error) {
+	incident.ID = t.store.allocateIncidentID()
+	t.writes = append(t.writes, func(s *memoryStore) { s.putIncident(incident) })
+	t.incidents[incident.ID] = incident
+	return incident.ID, nil
+}
+
+func (t *memoryTx) UpdateIncident(incident Incident) error {
+	t.writes = append(t.writes, func(s *memoryStore) { s.updateIncident(incident) })
+	if buffered, ok := t.incidents[incident.ID]; ok {
+		incident = applyIncidentUpdate(buffered, incident)
+	}
+	t.incidents[incident.ID] = incident
+	return nil
+}
+
+func (t *memoryTx) SaveBackfillCheckpoint(checkpoint BackfillCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	t.writes = append(t.writes, func(s *memoryStore) { s.saveBackfillCheckpoint(checkpoint) })
+	return nil
+}
+
+func (t *memoryTx) Commit() error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+	for _, write := range t.writes {
+		write(t.store)
+	}
+	t.writes = nil
+	return nil
+}
+
+func (t *memoryTx) Rollback() error {
+	t.writes = nil
+	return nil
+}
@@ -0,0 +1,421 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration upgrades the schema by one version. Every migration is idempotent, DBs created before
+// schema_version existed already have part of the schema and run all of them once.
+type Migration struct {
+	Version     int
+	Description string
+	up          func(db *sqlStore) error
+}
+
+// migrations in the order they are applied, append new ones with the next version
+var migrations = []Migration{
+	{1, "create cometbft_signatures", createSignatureTable},
+	{2, "add block_id_flag to cometbft_signatures", addBlockIDFlag},
+	{3, "add commit_height to cometbft_signatures", addCommitHeight},
+	{4, "add verification_status to cometbft_signatures", func(db *sqlStore) error {
+		// 0 = not verified, existing rows were never verified
+		_, err := addColumnIfMissing(db, "cometbft_signatures", "verification_status", "INTEGER NOT NULL DEFAULT 0")
+		return err
+	}},
+	{5, "add in_active_set and voting_power to cometbft_signatures", func(db *sqlStore) error {
+		// Existing rows predate the validator set lookup, they are assumed to be in the active set
+		if _, err := addColumnIfMissing(db, "cometbft_signatures", "in_active_set", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+			return err
+		}
+		_, err := addColumnIfMissing(db, "cometbft_signatures", "voting_power", "BIGINT NOT NULL DEFAULT 0")
+		return err
+	}},
+	{6, "add network participation to cometbft_signatures", func(db *sqlStore) error {
+		// Existing rows get isolated_miss 0 and a NULL network_committed_fraction, GetMissClassification only counts
+		// a miss as network-wide if the fraction is set, so their misses stay unclassified
+		if _, err := addColumnIfMissing(db, "cometbft_signatures", "isolated_miss", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if _, err := addColumnIfMissing(db, "cometbft_signatures", "network_committed_fraction", "DOUBLE PRECISION"); err != nil {
+			return err
+		}
+		_, err := addColumnIfMissing(db, "cometbft_signatures", "network_committed_power_fraction", "DOUBLE PRECISION")
+		return err
+	}},
+	{7, "create validator_participation", createParticipationTable},
+	{8, "create miss_incidents", createIncidentTable},
+	{9, "create validator_set_snapshots", createValidatorSetTable},
+	{10, "create known_gaps", createKnownGapTable},
+	{11, "create backfill_checkpoints", createBackfillTable},
+	{12, "add unique key and indexes to cometbft_signatures", addSignatureIndexes},
+	{13, "schedule heights skipped by older releases for backfill", scheduleSkippedHeights},
+}
+
+// LatestSchemaVersion is the schema version this build migrates DBs to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus is a migration and when it was applied, AppliedAt is empty while it is pending
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   string
+}
+
+func createSchemaVersionTable(db *sqlStore) error {
+	createTableSQL := `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	 );`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+	return nil
+}
+
+// GetSchemaVersion returns the highest applied migration, 0 for a DB that was never migrated
+func (db *sqlStore) GetSchemaVersion() (int, error) {
+	if err := createSchemaVersionTable(db); err != nil {
+		return 0, err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %v", err)
+	}
+	return version, nil
+}
+
+// GetMigrationStatus lists every known migration with the time it was applied, followed by migrations
+// recorded in the DB by a newer build
+func (db *sqlStore) GetMigrationStatus() ([]MigrationStatus, error) {
+	if err := createSchemaVersionTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT version, description, applied_at FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	var unknown []MigrationStatus
+	for rows.Next() {
+		var status MigrationStatus
+		if err := rows.Scan(&status.Version, &status.Description, &status.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %v", err)
+		}
+		applied[status.Version] = status
+		if status.Version > LatestSchemaVersion() {
+			unknown = append(unknown, status)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations)+len(unknown))
+	for _, migration := range migrations {
+		statuses = append(statuses, MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   applied[migration.Version].AppliedAt,
+		})
+	}
+	return append(statuses, unknown...), nil
+}
+
+// Migrate applies the pending migrations in order, each in its own transaction together with its schema_version row.
+// A DB migrated by a newer build is refused, this build would write rows the newer schema does not expect.
+func (db *sqlStore) Migrate() error {
+	current, err := db.GetSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than version %d supported by this build, upgrade cometbftsignrate", current, LatestSchemaVersion())
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		logger.PostLog("INFO", fmt.Sprintf("Applying schema migration %d: %s", migration.Version, migration.Description))
+		if err := applyMigration(db, migration); err != nil {
+			return fmt.Errorf("schema migration %d (%s) failed: %v", migration.Version, migration.Description, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sqlStore, migration Migration) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if db.dialect.migrationLock != "" {
+		if _, err := tx.Exec(db.dialect.migrationLock); err != nil {
+			return err
+		}
+	}
+	// another instance sharing the DB may have applied it while this one waited for the lock
+	var applied int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_version WHERE version = ?`, migration.Version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if err := migration.up(tx.sqlStore); err != nil {
+		return err
+	}
+	insertSQL := `INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(insertSQL, migration.Version, migration.Description, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createSignatureTable creates cometbft_signatures in the shape it had before migrations were versioned,
+// the columns added since are added by the migrations that follow
+func createSignatureTable(db *sqlStore) error {
+	createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS cometbft_signatures (
+		id %s,
+		timestamp TEXT NOT NULL,
+		chain_id TEXT NOT NULL,
+		address TEXT NOT NULL,
+		block_height INTEGER NOT NULL,
+		validatortimestamp TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		signaturefound INTEGER NOT NULL DEFAULT 0,
+		proposermatch INTEGER NOT NULL DEFAULT 0,
+		numtxs INTEGER NOT NULL DEFAULT 0,
+		emptyblock INTEGER NOT NULL DEFAULT 0
+	 );`, db.dialect.serialKey)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create cometbft_signatures table: %v", err)
+	}
+	return nil
+}
+
+// addBlockIDFlag adds block_id_flag, rows written before it was recorded only know whether the signature was found
+func addBlockIDFlag(db *sqlStore) error {
+	added, err := addColumnIfMissing(db, "cometbft_signatures", "block_id_flag", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+	_, err = db.Exec(`UPDATE cometbft_signatures SET block_id_flag = CASE WHEN signaturefound = 1 THEN 2 ELSE 1 END WHERE block_id_flag = 0`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate block_id_flag: %v", err)
+	}
+	return nil
+}
+
+// addCommitHeight adds commit_height. The signatures in block H's last_commit are the precommits for H-1,
+// rows written before commit_height was recorded are re-keyed to the height their commit refers to
+func addCommitHeight(db *sqlStore) error {
+	added, err := addColumnIfMissing(db, "cometbft_signatures", "commit_height", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+	_, err = db.Exec(`UPDATE cometbft_signatures SET commit_height = block_height - 1 WHERE commit_height = 0`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate commit_height: %v", err)
+	}
+	return nil
+}
+
+// addSignatureIndexes makes a row unique per chain, address and height so writes can upsert, duplicates left by
+// concurrent writers are removed first keeping the oldest row
+func addSignatureIndexes(db *sqlStore) error {
+	result, err := db.Exec(`DELETE FROM cometbft_signatures WHERE id NOT IN (
+		SELECT MIN(id) FROM cometbft_signatures GROUP BY chain_id, address, block_height)`)
+	if err != nil {
+		return fmt.Errorf("failed to remove duplicate rows: %v", err)
+	}
+	if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+		logger.PostLog("WARN", fmt.Sprintf("Removed %d duplicate rows from cometbft_signatures", removed))
+	}
+
+	indexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_cometbft_signatures_height ON cometbft_signatures (chain_id, address, block_height)`,
+		`CREATE INDEX IF NOT EXISTS idx_cometbft_signatures_commit ON cometbft_signatures (chain_id, address, commit_height)`,
+		`CREATE INDEX IF NOT EXISTS idx_cometbft_signatures_chain_height ON cometbft_signatures (chain_id, block_height)`,
+	}
+	for _, indexSQL := range indexes {
+		if _, err := db.Exec(indexSQL); err != nil {
+			return fmt.Errorf("failed to create cometbft_signatures index: %v", err)
+		}
+	}
+	return nil
+}
+
+// scheduleSkippedHeights finds the heights older releases did not store because the existence check ignored chain_id
+// and address: a height within the stored range of a validator that is missing for it but stored for another chain
+// or validator. They are reported and recorded as pending backfills, which the daemon runs with its gap scans.
+// Validators are scanned one at a time along their height index, only the holes in a validators own heights are
+// compared with the heights stored for the others.
+func scheduleSkippedHeights(db *sqlStore) error {
+	validators, err := storedValidators(db)
+	if err != nil {
+		return fmt.Errorf("failed to find skipped heights: %v", err)
+	}
+	chainIDs := make(map[string]bool)
+	for _, key := range validators {
+		chainIDs[key[0]] = true
+	}
+
+	skipped := make(map[string]map[int]bool)
+	perValidator := make(map[[2]string]int)
+	for _, key := range validators {
+		holes, err := heightHoles(db, key[0], key[1])
+		if err != nil {
+			return fmt.Errorf("failed to find skipped heights: %v", err)
+		}
+		// a height no one stored is a gap of the node, not one skipped by the existence check
+		missed := make(map[int]bool)
+		for _, hole := range holes {
+			for chainID := range chainIDs {
+				heights, err := storedHeights(db, chainID, hole.From, hole.To)
+				if err != nil {
+					return fmt.Errorf("failed to find skipped heights: %v", err)
+				}
+				for _, height := range heights {
+					missed[height] = true
+				}
+			}
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		if skipped[key[0]] == nil {
+			skipped[key[0]] = make(map[int]bool)
+		}
+		for height := range missed {
+			skipped[key[0]][height] = true
+		}
+		perValidator[key] = len(missed)
+	}
+
+	for key, count := range perValidator {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: key[0], Operation: "ScheduleSkippedHeights", Success: true, Message: fmt.Sprintf("%d heights of %s were skipped by an older release, scheduling them for backfill", count, key[1])})
+	}
+
+	// backfills run per chain and store every validator, the heights of a chain are merged into ranges
+	insertSQL := `INSERT INTO backfill_checkpoints (chain_id, from_height, to_height, next_height, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`
+	now := time.Now().UTC().Format(time.RFC3339)
+	for chainID, heights := range skipped {
+		sorted := make([]int, 0, len(heights))
+		for height := range heights {
+			sorted = append(sorted, height)
+		}
+		sort.Ints(sorted)
+
+		for i := 0; i < len(sorted); {
+			j := i
+			for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+				j++
+			}
+			if _, err := db.Exec(insertSQL, chainID, sorted[i], sorted[j], sorted[i], now); err != nil {
+				return fmt.Errorf("failed to schedule backfill of %d-%d for chain_id %s: %v", sorted[i], sorted[j], chainID, err)
+			}
+			i = j + 1
+		}
+	}
+	return nil
+}
+
+// storedValidators returns every chain_id and address with rows
+func storedValidators(db *sqlStore) ([][2]string, error) {
+	rows, err := db.Query(`SELECT chain_id, address FROM cometbft_signatures GROUP BY chain_id, address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var validators [][2]string
+	for rows.Next() {
+		var key [2]string
+		if err := rows.Scan(&key[0], &key[1]); err != nil {
+			return nil, err
+		}
+		validators = append(validators, key)
+	}
+	return validators, rows.Err()
+}
+
+// heightHoles returns the ranges between the lowest and highest height of address that have no row
+func heightHoles(db *sqlStore, chainID string, address string) ([]HeightRange, error) {
+	rows, err := db.Query(`SELECT block_height FROM cometbft_signatures WHERE chain_id = ? AND address = ? ORDER BY block_height`, chainID, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holes []HeightRange
+	previous := 0
+	for rows.Next() {
+		var height int
+		if err := rows.Scan(&height); err != nil {
+			return nil, err
+		}
+		if previous > 0 && height > previous+1 {
+			holes = append(holes, HeightRange{From: previous + 1, To: height - 1})
+		}
+		previous = height
+	}
+	return holes, rows.Err()
+}
+
+// storedHeights returns the heights in [fromHeight, toHeight] stored for any validator of chainID
+func storedHeights(db *sqlStore, chainID string, fromHeight int, toHeight int) ([]int, error) {
+	rows, err := db.Query(`SELECT DISTINCT block_height FROM cometbft_signatures WHERE chain_id = ? AND block_height BETWEEN ? AND ?`, chainID, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heights []int
+	for rows.Next() {
+		var height int
+		if err := rows.Scan(&height); err != nil {
+			return nil, err
+		}
+		heights = append(heights, height)
+	}
+	return heights, rows.Err()
+}
+
+// addColumnIfMissing adds column to table unless it already exists, returns true if it was added
+func addColumnIfMissing(db *sqlStore, table string, column string, definition string) (bool, error) {
+	rows, err := db.Query(db.dialect.columnsQuery, table)
+	if err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, fmt.Errorf("failed to read columns of %s: %v", table, err)
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return false, fmt.Errorf("failed to add column %s to %s: %v", column, table, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,134 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"fmt"
+	"sort"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ParticipationRecord is the vote of one validator in the last_commit of a block
+type ParticipationRecord struct {
+	Address     string
+	BlockIDFlag int
+	Proposed    bool
+}
+
+// LeaderboardEntry sums up the participation of one validator over a window of commit heights
+type LeaderboardEntry struct {
+	Address     string
+	Blocks      int
+	Committed   int
+	NilVoted    int
+	Absent      int
+	Proposed    int
+	SigningRate float64
+}
+
+func createParticipationTable(db *sqlStore) error {
+	createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS validator_participation (
+		id %s,
+		chain_id TEXT NOT NULL,
+		address TEXT NOT NULL,
+		block_height INTEGER NOT NULL,
+		commit_height INTEGER NOT NULL,
+		block_id_flag INTEGER NOT NULL DEFAULT 0,
+		proposed INTEGER NOT NULL DEFAULT 0
+	 );`, db.dialect.serialKey)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create validator_participation table: %v", err)
+	}
+
+	indexSQL := `CREATE UNIQUE INDEX IF NOT EXISTS idx_validator_participation_height
+		ON validator_participation (chain_id, block_height, address)`
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create validator_participation index: %v", err)
+	}
+	indexSQL = `CREATE INDEX IF NOT EXISTS idx_validator_participation_commit
+		ON validator_participation (chain_id, commit_height)`
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create validator_participation index: %v", err)
+	}
+	return nil
+}
+
+// InsertParticipation stores the vote of every validator in the commit of blockHeight, heights already stored are skipped.
+func (db *sqlStore) InsertParticipation(chainID string, blockHeight int, commitHeight int, records []ParticipationRecord) error {
+	insertSQL := `INSERT INTO validator_participation (chain_id, address, block_height, commit_height, block_id_flag, proposed)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`
+	for _, record := range records {
+		_, err := db.Exec(insertSQL, chainID, record.Address, blockHeight, commitHeight, record.BlockIDFlag, record.Proposed)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chainID, Operation: "InsertParticipation", Height: blockHeight, Success: false, Message: err.Error()})
+			return fmt.Errorf("failed to insert participation: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetLeaderboard returns the participation of every validator seen in the last window commit heights, best signing rate first
+func (db *sqlStore) GetLeaderboard(chainID string, window int) ([]LeaderboardEntry, error) {
+	querySQL := `
+		SELECT
+			address,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN block_id_flag = 2 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN block_id_flag = 3 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN block_id_flag NOT IN (2, 3) THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(proposed), 0)
+		FROM validator_participation
+		WHERE chain_id = ?
+			AND commit_height > (SELECT MAX(commit_height) FROM validator_participation WHERE chain_id = ?) - ?
+		GROUP BY address`
+	rows, err := db.Query(querySQL, chainID, chainID, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	var entries []LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		if err := rows.Scan(&entry.Address, &entry.Blocks, &entry.Committed, &entry.NilVoted, &entry.Absent, &entry.Proposed); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard for chain_id %s: %v", chainID, err)
+		}
+		if entry.Blocks > 0 {
+			entry.SigningRate = float64(entry.Committed) / float64(entry.Blocks)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard for chain_id %s: %v", chainID, err)
+	}
+
+	sortLeaderboard(entries)
+	return entries, nil
+}
+
+// sortLeaderboard orders entries by signing rate, then by proposed blocks
+func sortLeaderboard(entries []LeaderboardEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].SigningRate != entries[j].SigningRate {
+			return entries[i].SigningRate > entries[j].SigningRate
+		}
+		if entries[i].Proposed != entries[j].Proposed {
+			return entries[i].Proposed > entries[j].Proposed
+		}
+		return entries[i].Address < entries[j].Address
+	})
+}
+
+// DeleteOldParticipation removes participation older than the last recordCount heights of the chain
+func (db *sqlStore) DeleteOldParticipation(chainID string, recordCount int) error {
+	deleteSQL := `
+		DELETE FROM validator_participation
+		WHERE chain_id = ?
+			AND block_height <= (SELECT MAX(block_height) FROM validator_participation WHERE chain_id = ?) - ?`
+	_, err := db.Exec(deleteSQL, chainID, chainID, recordCount)
+	if err != nil {
+		return fmt.Errorf("failed to delete old participation: %w", err)
+	}
+	return nil
+}
@@ -8,42 +8,36 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func DeleteOldRecords(db *sql.DB, chainID string, recordCount int) error {
-	// Step 1: Get the ID of the `recordCount`-th most recent row for the given chain_id
+func (db *sqlStore) DeleteOldRecords(chainID string, recordCount int) error {
+	// Step 1: Get the latest block height for the given chain_id.
+	// The window is counted in heights, not rows, since every tracked validator stores one row per height.
 	query := fmt.Sprintf(`
-		WITH RankedRows AS (
-			SELECT id
-			FROM %s
-			WHERE chain_id = $1
-			ORDER BY %s DESC
-			LIMIT $2
-		)
-		SELECT id FROM RankedRows
-		ORDER BY id ASC
-		LIMIT 1;
-	`, "cometbft_signatures", "id")
+		SELECT MAX(block_height)
+		FROM %s
+		WHERE chain_id = $1;
+	`, "cometbft_signatures")
 
-	var thresholdID int
-	err := db.QueryRow(query, chainID, recordCount).Scan(&thresholdID)
+	var latestHeight sql.NullInt64
+	err := db.QueryRow(query, chainID).Scan(&latestHeight)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "DeleteOldRecords", Success: true, Message: "No records to prune"})
-			return nil
-		}
-		return fmt.Errorf("failed to get threshold ID: %w", err)
+		return fmt.Errorf("failed to get latest block height: %w", err)
+	}
+	if !latestHeight.Valid {
+		logger.PostLog("WARN", logger.ModuleDB{ChainID: chainID, Operation: "DeleteOldRecords", Success: true, Message: "No records to prune"})
+		return nil
 	}
 
-	// Step 2: Delete all records for the given chain_id with an ID less than the threshold
+	// Step 2: Delete all records for the given chain_id older than the last recordCount heights
 	deleteQuery := fmt.Sprintf(`
 		DELETE FROM %s
-		WHERE chain_id = $1 AND id < $2;
+		WHERE chain_id = $1 AND block_height <= $2;
 	`, "cometbft_signatures")
 
-	_, err = db.Exec(deleteQuery, chainID, thresholdID)
+	_, err = db.Exec(deleteQuery, chainID, latestHeight.Int64-int64(recordCount))
 	if err != nil {
 		return fmt.Errorf("failed to delete old records: %w", err)
 	}
 
 	logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "DeleteOldRecords", Success: true, Message: "Successfully deleted old records"})
 	return nil
-}
\ No newline at end of file
+}
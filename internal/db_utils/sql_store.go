@@ -0,0 +1,130 @@
+package db_utils
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect is what differs between the SQL databases a sqlStore runs on. Queries are written for SQLite
+// with ? placeholders and rewritten for the other dialects.
+type dialect struct {
+	// $1, $2, ... instead of ?
+	numberedParams bool
+	// bools are passed as 0 and 1, the schema stores them in INTEGER columns
+	boolsAsInts bool
+	// column definition of a primary key numbering the rows
+	serialKey string
+	// returns the column names of the table given as parameter
+	columnsQuery string
+	// runs first in every migration transaction, so instances sharing the DB migrate one after the other
+	migrationLock string
+}
+
+var sqliteDialect = &dialect{
+	serialKey:    "INTEGER PRIMARY KEY AUTOINCREMENT",
+	columnsQuery: `SELECT name FROM pragma_table_info(?)`,
+}
+
+var postgresDialect = &dialect{
+	numberedParams: true,
+	boolsAsInts:    true,
+	serialKey:      "BIGSERIAL PRIMARY KEY",
+	columnsQuery:   `SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?`,
+	migrationLock:  `SELECT pg_advisory_xact_lock(8316291910979384421)`,
+}
+
+func (d *dialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *dialect) args(args []interface{}) []interface{} {
+	if !d.boolsAsInts {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if v, ok := arg.(bool); ok {
+			arg = 0
+			if v {
+				arg = 1
+			}
+		}
+		converted[i] = arg
+	}
+	return converted
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlStore is the Store of a SQLite or PostgreSQL DB. Its queries run on q, the DB or the transaction of a sqlTx.
+type sqlStore struct {
+	sqlDB   *sql.DB
+	q       querier
+	dialect *dialect
+}
+
+func newSQLStore(db *sql.DB, d *dialect) *sqlStore {
+	return &sqlStore{sqlDB: db, q: db, dialect: d}
+}
+
+func (db *sqlStore) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.q.Exec(db.dialect.rebind(query), db.dialect.args(args)...)
+}
+
+func (db *sqlStore) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.q.Query(db.dialect.rebind(query), db.dialect.args(args)...)
+}
+
+func (db *sqlStore) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.q.QueryRow(db.dialect.rebind(query), db.dialect.args(args)...)
+}
+
+// Begin starts a transaction, a failed query does not roll it back by itself
+func (db *sqlStore) Begin() (Tx, error) {
+	return db.begin()
+}
+
+func (db *sqlStore) begin() (*sqlTx, error) {
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{sqlStore: &sqlStore{sqlDB: db.sqlDB, q: tx, dialect: db.dialect}, tx: tx}, nil
+}
+
+func (db *sqlStore) Close() error {
+	return db.sqlDB.Close()
+}
+
+// sqlTx runs the queries of its sqlStore in tx
+type sqlTx struct {
+	*sqlStore
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
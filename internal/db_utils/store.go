@@ -0,0 +1,73 @@
+package db_utils
+
+// BlockWriter stores the records of a block. It is implemented by a Store and by its transactions, the reads
+// are the ones needed while storing a block and see the writes of the transaction.
+type BlockWriter interface {
+	InsertBlockHeight(record BlockRecord) error
+	InsertParticipation(chainID string, blockHeight int, commitHeight int, records []ParticipationRecord) error
+	InsertValidatorSetSnapshot(chainID string, height int, timestamp string, validatorsHash string, members []ValidatorSetMember) error
+	GetValidatorSetHashAt(chainID string, height int) (string, error)
+	GetOpenIncident(chainID string, address string) (Incident, bool, error)
+	InsertIncident(incident Incident) (int64, error)
+	UpdateIncident(incident Incident) error
+	SaveBackfillCheckpoint(checkpoint BackfillCheckpoint) error
+}
+
+// Tx is a transaction of a Store, its writes become visible to other readers on Commit
+type Tx interface {
+	BlockWriter
+	Commit() error
+	Rollback() error
+}
+
+// Store is a storage backend, opened by OpenDB or InitDB. Every backend has to pass the conformance suite.
+type Store interface {
+	BlockWriter
+	Begin() (Tx, error)
+	Close() error
+
+	// Ingestion progress
+	GetLastBlockHeight(chainID string, currentNodeHeight int, signingWindow int, pruningEnabled bool) (int, error)
+	GetStoredHeightRange(chainID string) (int, int, error)
+	GetLatestStoredHeight(chainID string, address string) (int, error)
+	GetMissingHeights(chainID string, address string, fromHeight int, toHeight int) ([]HeightRange, error)
+	GetBackfillCheckpoint(chainID string, fromHeight int, toHeight int) (BackfillCheckpoint, bool, error)
+	GetPendingBackfills(chainID string) ([]BackfillCheckpoint, error)
+	InsertKnownGap(chainID string, fromHeight int, toHeight int, reason string) error
+	GetKnownGaps(chainID string, fromHeight int, toHeight int) ([]KnownGap, error)
+	GetKnownGapCount(chainID string, fromHeight int, toHeight int) (int, error)
+
+	// Signing window queries
+	GetAmountOfSignatureNotFound(chainID string, address string, numRecords int) (int, string, error)
+	GetTimestampDiff(chainID string, address string) (int, error)
+	GetNumberOfRecordsForChain(chainID string) (int, error)
+	GetNumberOfRecordsForValidator(chainID string, address string) (int, error)
+	GetNumberOfProposedBlocks(chainID string, address string, window int) (int, error)
+	GetNumberOfEmptyProposedBlocks(chainID string, address string, window int) (int, error)
+	GetBlockIDFlagCounts(chainID string, address string, window int) (int, int, int, error)
+	GetInvalidSignatureCount(chainID string, address string, window int) (int, error)
+	GetOutOfActiveSetCount(chainID string, address string, window int) (int, error)
+	GetUnreachableCount(chainID string, address string, window int) (int, error)
+	GetLatestActiveSetStatus(chainID string, address string) (bool, int64, error)
+	GetMissClassification(chainID string, address string, window int) (int, int, error)
+	GetCurrentConsecutiveMisses(chainID string, address string) (int, error)
+	GetAbsentCount(chainID string, address string, window int) (int, int, error)
+	GetAverageBlockTime(chainID string, address string, samples int) (float64, error)
+	GetSlashingWindowMisses(chainID string, address string, fromHeight int, toHeight int, window int) (int, int, error)
+	GetLeaderboard(chainID string, window int) ([]LeaderboardEntry, error)
+	GetIncidents(chainID string, address string, from string, to string, status string) ([]Incident, error)
+	GetVotingPowerHistory(chainID string, address string, fromHeight int, toHeight int) ([]VotingPowerSnapshot, error)
+
+	// Pruning, recordCount is the number of heights kept
+	DeleteOldRecords(chainID string, recordCount int) error
+	DeleteOldValidatorSets(chainID string, recordCount int) error
+	DeleteOldKnownGaps(chainID string, recordCount int) error
+	DeleteOldParticipation(chainID string, recordCount int) error
+}
+
+// Migrator is implemented by stores with a versioned schema
+type Migrator interface {
+	Migrate() error
+	GetSchemaVersion() (int, error)
+	GetMigrationStatus() ([]MigrationStatus, error)
+}
@@ -0,0 +1,144 @@
+package db_utils
+
+import (
+	"cometbftsignrate/internal/logger"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ValidatorSetMember is one validator of a validator set snapshot
+type ValidatorSetMember struct {
+	Address          string
+	PubKeyType       string
+	PubKey           string
+	VotingPower      int64
+	ProposerPriority int64
+	Rank             int
+}
+
+// VotingPowerSnapshot is the position of one validator in a validator set snapshot
+type VotingPowerSnapshot struct {
+	Height           int
+	Timestamp        string
+	ValidatorsHash   string
+	NumValidators    int
+	TotalVotingPower int64
+	InActiveSet      bool
+	VotingPower      int64
+	Rank             int
+}
+
+func createValidatorSetTable(db *sqlStore) error {
+	createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS validator_set_snapshots (
+		id %s,
+		chain_id TEXT NOT NULL,
+		height INTEGER NOT NULL,
+		timestamp TEXT NOT NULL,
+		validators_hash TEXT NOT NULL,
+		address TEXT NOT NULL,
+		pubkey_type TEXT NOT NULL,
+		pubkey TEXT NOT NULL,
+		voting_power BIGINT NOT NULL DEFAULT 0,
+		proposer_priority BIGINT NOT NULL DEFAULT 0,
+		rank INTEGER NOT NULL DEFAULT 0
+	 );`, db.dialect.serialKey)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create validator_set_snapshots table: %v", err)
+	}
+
+	indexSQL := `CREATE UNIQUE INDEX IF NOT EXISTS idx_validator_set_snapshots_height
+		ON validator_set_snapshots (chain_id, height, address)`
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create validator_set_snapshots index: %v", err)
+	}
+	return nil
+}
+
+// InsertValidatorSetSnapshot stores the validator set that became active at height
+func (db *sqlStore) InsertValidatorSetSnapshot(chainID string, height int, timestamp string, validatorsHash string, members []ValidatorSetMember) error {
+	insertSQL := `INSERT INTO validator_set_snapshots (chain_id, height, timestamp, validators_hash, address, pubkey_type, pubkey, voting_power, proposer_priority, rank)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`
+	for _, member := range members {
+		_, err := db.Exec(insertSQL, chainID, height, timestamp, validatorsHash, member.Address, member.PubKeyType, member.PubKey, member.VotingPower, member.ProposerPriority, member.Rank)
+		if err != nil {
+			logger.PostLog("ERROR", logger.ModuleDB{ChainID: chainID, Operation: "InsertValidatorSetSnapshot", Height: height, Success: false, Message: err.Error()})
+			return fmt.Errorf("failed to insert validator set snapshot: %v", err)
+		}
+	}
+
+	logger.PostLog("INFO", logger.ModuleDB{ChainID: chainID, Operation: "InsertValidatorSetSnapshot", Height: height, Success: true, Message: fmt.Sprintf("Stored validator set %s with %d validators", validatorsHash, len(members))})
+	return nil
+}
+
+// GetValidatorSetHashAt returns the validators_hash of the latest snapshot at or below height, empty if there is none
+func (db *sqlStore) GetValidatorSetHashAt(chainID string, height int) (string, error) {
+	var hash string
+	querySQL := `SELECT validators_hash FROM validator_set_snapshots WHERE chain_id = ? AND height <= ? ORDER BY height DESC LIMIT 1`
+	err := db.QueryRow(querySQL, chainID, height).Scan(&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get validator set hash for chain_id %s: %v", chainID, err)
+	}
+	return hash, nil
+}
+
+// GetVotingPowerHistory returns the position of address in every snapshot between fromHeight and toHeight, oldest first.
+// A toHeight of 0 means no upper bound.
+func (db *sqlStore) GetVotingPowerHistory(chainID string, address string, fromHeight int, toHeight int) ([]VotingPowerSnapshot, error) {
+	querySQL := `
+		SELECT
+			height,
+			MAX(timestamp),
+			MAX(validators_hash),
+			COUNT(*),
+			COALESCE(SUM(voting_power), 0),
+			COALESCE(SUM(CASE WHEN address = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN address = ? THEN voting_power ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN address = ? THEN rank ELSE 0 END), 0)
+		FROM validator_set_snapshots
+		WHERE chain_id = ? AND height >= ? AND (? = 0 OR height <= ?)
+		GROUP BY height
+		ORDER BY height ASC`
+	rows, err := db.Query(querySQL, address, address, address, chainID, fromHeight, toHeight, toHeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voting power history for chain_id %s: %v", chainID, err)
+	}
+	defer rows.Close()
+
+	var history []VotingPowerSnapshot
+	for rows.Next() {
+		var snapshot VotingPowerSnapshot
+		err := rows.Scan(&snapshot.Height, &snapshot.Timestamp, &snapshot.ValidatorsHash, &snapshot.NumValidators, &snapshot.TotalVotingPower, &snapshot.InActiveSet, &snapshot.VotingPower, &snapshot.Rank)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan voting power history for chain_id %s: %v", chainID, err)
+		}
+		history = append(history, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get voting power history for chain_id %s: %v", chainID, err)
+	}
+	return history, nil
+}
+
+// DeleteOldValidatorSets removes snapshots older than the last recordCount heights of the chain.
+// The newest snapshot before that is kept since it is still the active set at the start of the window.
+func (db *sqlStore) DeleteOldValidatorSets(chainID string, recordCount int) error {
+	deleteSQL := `
+		DELETE FROM validator_set_snapshots
+		WHERE chain_id = ?
+			AND height < (
+				SELECT MAX(height) FROM validator_set_snapshots
+				WHERE chain_id = ?
+					AND height <= (SELECT MAX(block_height) FROM cometbft_signatures WHERE chain_id = ?) - ?
+			)`
+	_, err := db.Exec(deleteSQL, chainID, chainID, chainID, recordCount)
+	if err != nil {
+		return fmt.Errorf("failed to delete old validator sets: %w", err)
+	}
+	return nil
+}
@@ -14,6 +14,8 @@ type LogEntry struct {
 	ModuleDB  *ModuleDB `json:"module_db,omitempty"`
 	ModuleHTTP *ModuleHTTP `json:"module_http,omitempty"`
 	ModulePruner *ModulePruner `json:"module_pruner,omitempty"`
+	ModuleSupervisor *ModuleSupervisor `json:"module_supervisor,omitempty"`
+	ModuleReconciler *ModuleReconciler `json:"module_reconciler,omitempty"`
 }
 
 type Message struct {
@@ -46,6 +48,24 @@ type ModulePruner struct {
 	Success   bool   `json:"success"`
 }
 
+type ModuleSupervisor struct {
+	ChainID   string `json:"chain_id"`
+	Worker    string `json:"worker"`
+	State     string `json:"state"`
+	Restarts  int    `json:"restarts,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type ModuleReconciler struct {
+	ChainID   string `json:"chain_id"`
+	Address   string `json:"address"`
+	Height    int    `json:"height"`
+	ChainMissed int  `json:"chain_missed"`
+	LocalMissed int  `json:"local_missed"`
+	Divergence int   `json:"divergence"`
+	Message   string `json:"message,omitempty"`
+}
+
 func PostLog(logLevel string, payload interface{}) {
 	entry := LogEntry{
 		Timestamp: time.Now().Format(time.RFC3339),
@@ -62,6 +82,10 @@ func PostLog(logLevel string, payload interface{}) {
 		entry.ModuleHTTP = &v
 	case ModulePruner: // Treat as a ModulePruner
 		entry.ModulePruner = &v
+	case ModuleSupervisor: // Treat as a ModuleSupervisor
+		entry.ModuleSupervisor = &v
+	case ModuleReconciler: // Treat as a ModuleReconciler
+		entry.ModuleReconciler = &v
 	default:
 		PostLog("ERROR", "Unsupported logging payload type")
 		os.Exit(1)
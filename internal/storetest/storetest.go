@@ -0,0 +1,958 @@
+// Package storetest is the conformance suite every db_utils.Store backend has to pass. It is only imported by tests,
+// every case writes to its own chain_id so the store may hold other data.
+package storetest
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cometbftsignrate/internal/db_utils"
+)
+
+// Result is the outcome of one case, Err is nil if it passed
+type Result struct {
+	Name string
+	Err  error
+}
+
+type testCase struct {
+	name string
+	run  func(db db_utils.Store, chainID string) error
+}
+
+var cases = []testCase{
+	{"empty chain", testEmptyChain},
+	{"insert and upsert", testUpsert},
+	{"signing window", testSigningWindow},
+	{"timestamp diff", testTimestampDiff},
+	{"commit height window", testCommitHeightWindow},
+	{"missing heights", testMissingHeights},
+	{"transactions", testTransactions},
+	{"incidents", testIncidents},
+	{"validator sets", testValidatorSets},
+	{"known gaps", testKnownGaps},
+	{"leaderboard", testLeaderboard},
+	{"backfill checkpoints", testBackfillCheckpoints},
+	{"pruning", testPruning},
+}
+
+// Run runs every case against db
+func Run(db db_utils.Store) []Result {
+	prefix := fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+	results := make([]Result, 0, len(cases))
+	for i, c := range cases {
+		results = append(results, Result{Name: c.name, Err: c.run(db, fmt.Sprintf("%s-%d", prefix, i))})
+	}
+	return results
+}
+
+const (
+	validatorA = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	validatorB = "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"
+	validatorC = "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC"
+)
+
+// genesis is the time of height 0, blocks follow every 6 seconds
+var genesis = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func blockTime(height int) string {
+	return genesis.Add(time.Duration(height) * 6 * time.Second).Format(time.RFC3339)
+}
+
+// signedRecord is the row of a validator that committed the commit carried in the block of height
+func signedRecord(chainID string, address string, height int) db_utils.BlockRecord {
+	return db_utils.BlockRecord{
+		Timestamp:                     blockTime(height),
+		ChainID:                       chainID,
+		Address:                       address,
+		BlockHeight:                   height,
+		CommitHeight:                  height - 1,
+		SignatureFound:                true,
+		BlockIDFlag:                   2,
+		ValidatorTimestamp:            blockTime(height),
+		Signature:                     "c2lnbmF0dXJl",
+		NumTXs:                        1,
+		VerificationStatus:            1,
+		InActiveSet:                   true,
+		VotingPower:                   10,
+		NetworkCommittedFraction:      1,
+		NetworkCommittedPowerFraction: sql.NullFloat64{Float64: 1, Valid: true},
+	}
+}
+
+// missedRecord is the row of a validator whose vote for the commit carried in the block of height has flag
+func missedRecord(chainID string, address string, height int, flag int) db_utils.BlockRecord {
+	record := signedRecord(chainID, address, height)
+	record.SignatureFound = false
+	record.BlockIDFlag = flag
+	record.Signature = ""
+	record.VerificationStatus = 0
+	return record
+}
+
+func insertRecords(db db_utils.BlockWriter, records ...db_utils.BlockRecord) error {
+	for _, record := range records {
+		if err := db.InsertBlockHeight(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertSigned stores a signed row of every address for every height in [from, to]
+func insertSigned(db db_utils.BlockWriter, chainID string, from int, to int, addresses ...string) error {
+	for height := from; height <= to; height++ {
+		for _, address := range addresses {
+			if err := db.InsertBlockHeight(signedRecord(chainID, address, height)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expect returns an error naming what if got differs from want
+func expect(what string, got interface{}, want interface{}) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s: got %+v, want %+v", what, got, want)
+	}
+	return nil
+}
+
+// firstError returns the first non-nil error
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testEmptyChain(db db_utils.Store, chainID string) error {
+	lastHeight, err := db.GetLastBlockHeight(chainID, 1000, 100, false)
+	if err != nil {
+		return err
+	}
+	minHeight, maxHeight, err := db.GetStoredHeightRange(chainID)
+	if err != nil {
+		return err
+	}
+	latest, err := db.GetLatestStoredHeight(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	records, err := db.GetNumberOfRecordsForChain(chainID)
+	if err != nil {
+		return err
+	}
+	inActiveSet, votingPower, err := db.GetLatestActiveSetStatus(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	averageBlockTime, err := db.GetAverageBlockTime(chainID, validatorA, 100)
+	if err != nil {
+		return err
+	}
+	consecutive, err := db.GetCurrentConsecutiveMisses(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	missing, err := db.GetMissingHeights(chainID, validatorA, 1, 3)
+	if err != nil {
+		return err
+	}
+	hash, err := db.GetValidatorSetHashAt(chainID, 10)
+	if err != nil {
+		return err
+	}
+	gaps, err := db.GetKnownGaps(chainID, 0, 0)
+	if err != nil {
+		return err
+	}
+	pending, err := db.GetPendingBackfills(chainID)
+	if err != nil {
+		return err
+	}
+	leaderboard, err := db.GetLeaderboard(chainID, 100)
+	if err != nil {
+		return err
+	}
+	incidents, err := db.GetIncidents(chainID, "", "", "", "")
+	if err != nil {
+		return err
+	}
+	if _, _, err := db.GetAmountOfSignatureNotFound(chainID, validatorA, 100); err == nil {
+		return fmt.Errorf("GetAmountOfSignatureNotFound: expected an error for an unknown chain")
+	}
+
+	return firstError(
+		expect("GetLastBlockHeight", lastHeight, 0),
+		expect("GetStoredHeightRange", [2]int{minHeight, maxHeight}, [2]int{0, 0}),
+		expect("GetLatestStoredHeight", latest, 0),
+		expect("GetNumberOfRecordsForChain", records, 0),
+		expect("GetLatestActiveSetStatus", []interface{}{inActiveSet, votingPower}, []interface{}{false, int64(0)}),
+		expect("GetAverageBlockTime", averageBlockTime, 0.0),
+		expect("GetCurrentConsecutiveMisses", consecutive, 0),
+		expect("GetMissingHeights", missing, []db_utils.HeightRange{{From: 1, To: 3}}),
+		expect("GetValidatorSetHashAt", hash, ""),
+		expect("GetKnownGaps", len(gaps), 0),
+		expect("GetPendingBackfills", len(pending), 0),
+		expect("GetLeaderboard", len(leaderboard), 0),
+		expect("GetIncidents", len(incidents), 0),
+	)
+}
+
+func testUpsert(db db_utils.Store, chainID string) error {
+	if err := insertSigned(db, chainID, 1, 20, validatorA, validatorB); err != nil {
+		return err
+	}
+	// storing a height again replaces its row
+	if err := insertRecords(db, missedRecord(chainID, validatorA, 10, 1)); err != nil {
+		return err
+	}
+
+	chainRecords, err := db.GetNumberOfRecordsForChain(chainID)
+	if err != nil {
+		return err
+	}
+	validatorRecords, err := db.GetNumberOfRecordsForValidator(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	minHeight, maxHeight, err := db.GetStoredHeightRange(chainID)
+	if err != nil {
+		return err
+	}
+	latest, err := db.GetLatestStoredHeight(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	lastHeight, err := db.GetLastBlockHeight(chainID, 25, 100, false)
+	if err != nil {
+		return err
+	}
+	// a node far ahead of the stored heights restarts at the signing window when pruning
+	clamped, err := db.GetLastBlockHeight(chainID, 500, 100, true)
+	if err != nil {
+		return err
+	}
+	committed, nilVoted, absent, err := db.GetBlockIDFlagCounts(chainID, validatorA, 100)
+	if err != nil {
+		return err
+	}
+	missed, latestTimestamp, err := db.GetAmountOfSignatureNotFound(chainID, validatorA, 100)
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetNumberOfRecordsForChain", chainRecords, 40),
+		expect("GetNumberOfRecordsForValidator", validatorRecords, 20),
+		expect("GetStoredHeightRange", [2]int{minHeight, maxHeight}, [2]int{1, 20}),
+		expect("GetLatestStoredHeight", latest, 20),
+		expect("GetLastBlockHeight", lastHeight, 20),
+		expect("GetLastBlockHeight with pruning", clamped, 400),
+		expect("GetBlockIDFlagCounts", [3]int{committed, nilVoted, absent}, [3]int{19, 0, 1}),
+		expect("GetAmountOfSignatureNotFound", []interface{}{missed, latestTimestamp}, []interface{}{1, blockTime(20)}),
+	)
+}
+
+func testSigningWindow(db db_utils.Store, chainID string) error {
+	if err := insertSigned(db, chainID, 1, 30, validatorA); err != nil {
+		return err
+	}
+
+	outOfSet := missedRecord(chainID, validatorA, 26, 1)
+	outOfSet.InActiveSet = false
+	outOfSet.VotingPower = 0
+	invalid := signedRecord(chainID, validatorA, 27)
+	invalid.VerificationStatus = 2
+	isolated := missedRecord(chainID, validatorA, 28, 1)
+	isolated.IsolatedMiss = true
+	networkWide := missedRecord(chainID, validatorA, 29, 3)
+	networkWide.NetworkCommittedFraction = 0.5
+	proposedEmpty := signedRecord(chainID, validatorA, 30)
+	proposedEmpty.ProposerMatch = true
+	proposedEmpty.NumTXs = 0
+	proposed := signedRecord(chainID, validatorA, 25)
+	proposed.ProposerMatch = true
+	proposed.NumTXs = 3
+	// outside of the window of 10
+	proposedOld := signedRecord(chainID, validatorA, 10)
+	proposedOld.ProposerMatch = true
+	missedOld := missedRecord(chainID, validatorA, 5, 1)
+	if err := insertRecords(db, outOfSet, invalid, isolated, networkWide, proposedEmpty, proposed, proposedOld, missedOld); err != nil {
+		return err
+	}
+
+	// a window of 10 covers the commit heights 20-29 carried by the blocks 21-30, block 26 is out of the active set
+	missed, latestTimestamp, err := db.GetAmountOfSignatureNotFound(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	committed, nilVoted, absent, err := db.GetBlockIDFlagCounts(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	invalidCount, err := db.GetInvalidSignatureCount(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	outOfSetCount, err := db.GetOutOfActiveSetCount(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	isolatedCount, networkWideCount, err := db.GetMissClassification(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	absentCount, activeRecords, err := db.GetAbsentCount(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	proposedCount, err := db.GetNumberOfProposedBlocks(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	emptyCount, err := db.GetNumberOfEmptyProposedBlocks(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	inActiveSet, votingPower, err := db.GetLatestActiveSetStatus(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	averageBlockTime, err := db.GetAverageBlockTime(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	// fewer heights stored than samples falls back to the oldest stored height
+	averageAllBlockTime, err := db.GetAverageBlockTime(chainID, validatorA, 100)
+	if err != nil {
+		return err
+	}
+	slashingMissed, slashingRecords, err := db.GetSlashingWindowMisses(chainID, validatorA, 1, 30, 10)
+	if err != nil {
+		return err
+	}
+	sinceMissed, sinceRecords, err := db.GetSlashingWindowMisses(chainID, validatorA, 29, 30, 10)
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetAmountOfSignatureNotFound", []interface{}{missed, latestTimestamp}, []interface{}{2, blockTime(30)}),
+		expect("GetBlockIDFlagCounts", [3]int{committed, nilVoted, absent}, [3]int{7, 1, 1}),
+		expect("GetInvalidSignatureCount", invalidCount, 1),
+		expect("GetOutOfActiveSetCount", outOfSetCount, 1),
+		expect("GetMissClassification", [2]int{isolatedCount, networkWideCount}, [2]int{1, 1}),
+		expect("GetAbsentCount", [2]int{absentCount, activeRecords}, [2]int{1, 9}),
+		expect("GetNumberOfProposedBlocks", proposedCount, 2),
+		expect("GetNumberOfEmptyProposedBlocks", emptyCount, 1),
+		expect("GetLatestActiveSetStatus", []interface{}{inActiveSet, votingPower}, []interface{}{true, int64(10)}),
+		expect("GetAverageBlockTime", averageBlockTime, 6.0),
+		expect("GetAverageBlockTime of every height", averageAllBlockTime, 6.0),
+		expect("GetSlashingWindowMisses", [2]int{slashingMissed, slashingRecords}, [2]int{1, 10}),
+		expect("GetSlashingWindowMisses from 29", [2]int{sinceMissed, sinceRecords}, [2]int{0, 2}),
+	)
+}
+
+func testCommitHeightWindow(db db_utils.Store, chainID string) error {
+	// blocks 1-10 carry the commits 0-9, block 11 could not be fetched and the blocks 12-20 carry the commits 10-18
+	for height := 1; height <= 20; height++ {
+		if height == 11 {
+			continue
+		}
+		record := signedRecord(chainID, validatorA, height)
+		switch height {
+		case 10:
+			// the validator set was unknown, only the share of validators that committed was recorded
+			record = missedRecord(chainID, validatorA, height, 1)
+			record.NetworkCommittedFraction = 0.5
+			record.NetworkCommittedPowerFraction = sql.NullFloat64{}
+		case 15:
+			record = missedRecord(chainID, validatorA, height, 1)
+			record.IsolatedMiss = true
+			record.NetworkCommittedPowerFraction = sql.NullFloat64{}
+		case 20:
+			record = missedRecord(chainID, validatorA, height, 3)
+			record.NetworkCommittedFraction = 0.4
+			record.NetworkCommittedPowerFraction = sql.NullFloat64{Float64: 0.4, Valid: true}
+		}
+		if height > 11 {
+			record.CommitHeight = height - 2
+		}
+		if err := db.InsertBlockHeight(record); err != nil {
+			return err
+		}
+	}
+	if err := db.InsertKnownGap(chainID, 11, 11, "pruned"); err != nil {
+		return err
+	}
+
+	// a window of 10 covers the commits 9-18, the blocks 10 and 12-20
+	missed, _, err := db.GetAmountOfSignatureNotFound(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	committed, nilVoted, absent, err := db.GetBlockIDFlagCounts(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	isolated, networkWide, err := db.GetMissClassification(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	absentCount, activeRecords, err := db.GetAbsentCount(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	unreachable, err := db.GetUnreachableCount(chainID, validatorA, 10)
+	if err != nil {
+		return err
+	}
+	// a window of 9 covers the commits 10-18, the blocks 12-20
+	missedShorter, _, err := db.GetAmountOfSignatureNotFound(chainID, validatorA, 9)
+	if err != nil {
+		return err
+	}
+	isolatedShorter, networkWideShorter, err := db.GetMissClassification(chainID, validatorA, 9)
+	if err != nil {
+		return err
+	}
+	latest, err := db.GetLatestStoredHeight(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	missing, err := db.GetMissingHeights(chainID, validatorA, 1, 20)
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetAmountOfSignatureNotFound", missed, 3),
+		expect("GetBlockIDFlagCounts", [3]int{committed, nilVoted, absent}, [3]int{7, 1, 2}),
+		expect("GetMissClassification", [2]int{isolated, networkWide}, [2]int{1, 2}),
+		expect("GetAbsentCount", [2]int{absentCount, activeRecords}, [2]int{2, 10}),
+		expect("GetUnreachableCount", unreachable, 1),
+		expect("GetAmountOfSignatureNotFound of 9", missedShorter, 2),
+		expect("GetMissClassification of 9", [2]int{isolatedShorter, networkWideShorter}, [2]int{1, 1}),
+		expect("GetLatestStoredHeight", latest, 20),
+		expect("GetMissingHeights", missing, []db_utils.HeightRange{{From: 11, To: 11}}),
+	)
+}
+
+func testTimestampDiff(db db_utils.Store, chainID string) error {
+	// the validator timestamp is 3s after the block at odd heights and 1s after it at even ones
+	signedAt := func(height int) db_utils.BlockRecord {
+		record := signedRecord(chainID, validatorA, height)
+		diff := time.Second
+		if height%2 == 1 {
+			diff = 3 * time.Second
+		}
+		record.ValidatorTimestamp = genesis.Add(time.Duration(height)*6*time.Second + diff).Format(time.RFC3339)
+		return record
+	}
+	for height := 1; height <= 40; height++ {
+		record := signedAt(height)
+		switch {
+		case height <= 10:
+			// older than the newest 25 signed rows
+			record.ValidatorTimestamp = genesis.Add(time.Duration(height)*6*time.Second + 9*time.Second).Format(time.RFC3339)
+		case height == 20 || height == 30:
+			// a miss has no validator timestamp and is not averaged
+			record = missedRecord(chainID, validatorA, height, 1)
+			record.ValidatorTimestamp = ""
+		}
+		if err := db.InsertBlockHeight(record); err != nil {
+			return err
+		}
+	}
+
+	diff, err := db.GetTimestampDiff(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	unknownDiff, err := db.GetTimestampDiff(chainID, validatorB)
+	if err != nil {
+		return err
+	}
+
+	// the newest 25 signed rows are heights 14-40 without 20 and 30, 13 odd and 12 even ones
+	return firstError(
+		expect("GetTimestampDiff", diff, (13*3000+12*1000)/25),
+		expect("GetTimestampDiff without rows", unknownDiff, 0),
+	)
+}
+
+func testMissingHeights(db db_utils.Store, chainID string) error {
+	for _, height := range []int{3, 4, 5, 8, 10} {
+		if err := insertRecords(db, signedRecord(chainID, validatorA, height)); err != nil {
+			return err
+		}
+	}
+
+	missing, err := db.GetMissingHeights(chainID, validatorA, 1, 12)
+	if err != nil {
+		return err
+	}
+	none, err := db.GetMissingHeights(chainID, validatorA, 3, 5)
+	if err != nil {
+		return err
+	}
+	other, err := db.GetMissingHeights(chainID, validatorB, 4, 9)
+	if err != nil {
+		return err
+	}
+	empty, err := db.GetMissingHeights(chainID, validatorA, 5, 4)
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetMissingHeights", missing, []db_utils.HeightRange{{From: 1, To: 2}, {From: 6, To: 7}, {From: 9, To: 9}, {From: 11, To: 12}}),
+		expect("GetMissingHeights without holes", len(none), 0),
+		expect("GetMissingHeights of another validator", other, []db_utils.HeightRange{{From: 4, To: 9}}),
+		expect("GetMissingHeights of an empty range", len(empty), 0),
+	)
+}
+
+func testTransactions(db db_utils.Store, chainID string) error {
+	members := []db_utils.ValidatorSetMember{{Address: validatorA, PubKeyType: "tendermint/PubKeyEd25519", PubKey: "cHVia2V5", VotingPower: 10, Rank: 1}}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertSigned(tx, chainID, 1, 1, validatorA); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.InsertValidatorSetSnapshot(chainID, 1, blockTime(1), "HASH1", members); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// the transaction reads its own writes
+	txHash, err := tx.GetValidatorSetHashAt(chainID, 1)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	rolledBackRecords, err := db.GetNumberOfRecordsForChain(chainID)
+	if err != nil {
+		return err
+	}
+	rolledBackHash, err := db.GetValidatorSetHashAt(chainID, 1)
+	if err != nil {
+		return err
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertSigned(tx, chainID, 1, 3, validatorA); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.SaveBackfillCheckpoint(db_utils.BackfillCheckpoint{ChainID: chainID, FromHeight: 1, ToHeight: 10, NextHeight: 4}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committedRecords, err := db.GetNumberOfRecordsForChain(chainID)
+	if err != nil {
+		return err
+	}
+	checkpoint, ok, err := db.GetBackfillCheckpoint(chainID, 1, 10)
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetValidatorSetHashAt in the transaction", txHash, "HASH1"),
+		expect("GetNumberOfRecordsForChain after rollback", rolledBackRecords, 0),
+		expect("GetValidatorSetHashAt after rollback", rolledBackHash, ""),
+		expect("GetNumberOfRecordsForChain after commit", committedRecords, 3),
+		expect("GetBackfillCheckpoint after commit", []interface{}{ok, checkpoint.NextHeight}, []interface{}{true, 4}),
+	)
+}
+
+func testIncidents(db db_utils.Store, chainID string) error {
+	incident := db_utils.Incident{
+		ChainID:     chainID,
+		Address:     validatorA,
+		StartHeight: 10,
+		EndHeight:   12,
+		StartTime:   blockTime(10),
+		EndTime:     blockTime(12),
+		BlockCount:  3,
+		Status:      db_utils.IncidentOpen,
+	}
+	id, err := db.InsertIncident(incident)
+	if err != nil {
+		return err
+	}
+	incident.ID = id
+	open, ok, err := db.GetOpenIncident(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	consecutive, err := db.GetCurrentConsecutiveMisses(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("GetOpenIncident", []interface{}{ok, open}, []interface{}{true, incident}),
+		expect("GetCurrentConsecutiveMisses", consecutive, 3),
+	); err != nil {
+		return err
+	}
+
+	incident.EndHeight = 14
+	incident.EndTime = blockTime(14)
+	incident.BlockCount = 5
+	incident.Status = db_utils.IncidentResolved
+	incident.ResolvedHeight = 15
+	if err := db.UpdateIncident(incident); err != nil {
+		return err
+	}
+	other := db_utils.Incident{
+		ChainID:     chainID,
+		Address:     validatorB,
+		StartHeight: 20,
+		EndHeight:   20,
+		StartTime:   blockTime(20),
+		EndTime:     blockTime(20),
+		BlockCount:  1,
+		Status:      db_utils.IncidentOpen,
+	}
+	if other.ID, err = db.InsertIncident(other); err != nil {
+		return err
+	}
+
+	_, stillOpen, err := db.GetOpenIncident(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	resolvedConsecutive, err := db.GetCurrentConsecutiveMisses(chainID, validatorA)
+	if err != nil {
+		return err
+	}
+	all, err := db.GetIncidents(chainID, "", "", "", "")
+	if err != nil {
+		return err
+	}
+	byAddress, err := db.GetIncidents(chainID, validatorA, "", "", "")
+	if err != nil {
+		return err
+	}
+	byStatus, err := db.GetIncidents(chainID, "", "", "", db_utils.IncidentOpen)
+	if err != nil {
+		return err
+	}
+	byTime, err := db.GetIncidents(chainID, "", blockTime(15), blockTime(25), "")
+	if err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetOpenIncident after resolving", stillOpen, false),
+		expect("GetCurrentConsecutiveMisses after resolving", resolvedConsecutive, 0),
+		expect("GetIncidents", all, []db_utils.Incident{other, incident}),
+		expect("GetIncidents by address", byAddress, []db_utils.Incident{incident}),
+		expect("GetIncidents by status", byStatus, []db_utils.Incident{other}),
+		expect("GetIncidents by time", byTime, []db_utils.Incident{other}),
+	)
+}
+
+func testValidatorSets(db db_utils.Store, chainID string) error {
+	// voting power beyond 32 bits
+	first := []db_utils.ValidatorSetMember{
+		{Address: validatorA, PubKeyType: "tendermint/PubKeyEd25519", PubKey: "cHVia2V5QQ==", VotingPower: 5000000000, ProposerPriority: -5000000000, Rank: 1},
+		{Address: validatorB, PubKeyType: "tendermint/PubKeyEd25519", PubKey: "cHVia2V5Qg==", VotingPower: 1, Rank: 2},
+	}
+	second := []db_utils.ValidatorSetMember{
+		{Address: validatorB, PubKeyType: "tendermint/PubKeyEd25519", PubKey: "cHVia2V5Qg==", VotingPower: 1, Rank: 1},
+	}
+	if err := db.InsertValidatorSetSnapshot(chainID, 10, blockTime(10), "HASH10", first); err != nil {
+		return err
+	}
+	if err := db.InsertValidatorSetSnapshot(chainID, 20, blockTime(20), "HASH20", second); err != nil {
+		return err
+	}
+	// a snapshot already stored is left untouched
+	if err := db.InsertValidatorSetSnapshot(chainID, 10, blockTime(10), "HASH10", first); err != nil {
+		return err
+	}
+
+	var hashes []string
+	for _, height := range []int{9, 10, 15, 25} {
+		hash, err := db.GetValidatorSetHashAt(chainID, height)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	history, err := db.GetVotingPowerHistory(chainID, validatorA, 0, 0)
+	if err != nil {
+		return err
+	}
+	bounded, err := db.GetVotingPowerHistory(chainID, validatorA, 0, 15)
+	if err != nil {
+		return err
+	}
+
+	wantFirst := db_utils.VotingPowerSnapshot{Height: 10, Timestamp: blockTime(10), ValidatorsHash: "HASH10", NumValidators: 2, TotalVotingPower: 5000000001, InActiveSet: true, VotingPower: 5000000000, Rank: 1}
+	wantSecond := db_utils.VotingPowerSnapshot{Height: 20, Timestamp: blockTime(20), ValidatorsHash: "HASH20", NumValidators: 1, TotalVotingPower: 1}
+	if err := firstError(
+		expect("GetValidatorSetHashAt", hashes, []string{"", "HASH10", "HASH10", "HASH20"}),
+		expect("GetVotingPowerHistory", history, []db_utils.VotingPowerSnapshot{wantFirst, wantSecond}),
+		expect("GetVotingPowerHistory up to 15", bounded, []db_utils.VotingPowerSnapshot{wantFirst}),
+	); err != nil {
+		return err
+	}
+
+	// keeping 5 heights below block 30 keeps the snapshot of 20, it is still the active set at 25
+	if err := insertSigned(db, chainID, 30, 30, validatorA); err != nil {
+		return err
+	}
+	if err := db.DeleteOldValidatorSets(chainID, 5); err != nil {
+		return err
+	}
+	pruned, err := db.GetVotingPowerHistory(chainID, validatorA, 0, 0)
+	if err != nil {
+		return err
+	}
+	return expect("GetVotingPowerHistory after pruning", pruned, []db_utils.VotingPowerSnapshot{wantSecond})
+}
+
+func testKnownGaps(db db_utils.Store, chainID string) error {
+	for _, gap := range [][2]int{{1, 10}, {1, 10}, {5, 15}, {30, 40}} {
+		if err := db.InsertKnownGap(chainID, gap[0], gap[1], "pruned"); err != nil {
+			return err
+		}
+	}
+
+	unbounded, err := db.GetKnownGaps(chainID, 12, 0)
+	if err != nil {
+		return err
+	}
+	bounded, err := db.GetKnownGaps(chainID, 0, 4)
+	if err != nil {
+		return err
+	}
+	count, err := db.GetKnownGapCount(chainID, 1, 20)
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("GetKnownGaps from 12", gapRanges(unbounded), [][2]int{{5, 15}, {30, 40}}),
+		expect("GetKnownGaps up to 4", gapRanges(bounded), [][2]int{{1, 10}}),
+		expect("GetKnownGapCount", count, 15),
+	); err != nil {
+		return err
+	}
+	for _, gap := range unbounded {
+		if gap.ChainID != chainID || gap.Reason != "pruned" || gap.RecordedAt == "" {
+			return fmt.Errorf("GetKnownGaps: incomplete gap %+v", gap)
+		}
+	}
+
+	// keeping 20 heights below block 50 drops the gaps ending at or before 30
+	if err := insertSigned(db, chainID, 50, 50, validatorA); err != nil {
+		return err
+	}
+	if err := db.DeleteOldKnownGaps(chainID, 20); err != nil {
+		return err
+	}
+	remaining, err := db.GetKnownGaps(chainID, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// blocks 64 and 65 carrying commits 63 and 64 could not be fetched, the newest commit is 69
+	if err := insertSigned(db, chainID, 60, 63, validatorB); err != nil {
+		return err
+	}
+	if err := insertSigned(db, chainID, 66, 70, validatorB); err != nil {
+		return err
+	}
+	if err := db.InsertKnownGap(chainID, 64, 65, "pruned"); err != nil {
+		return err
+	}
+	unreachable, err := db.GetUnreachableCount(chainID, validatorB, 6)
+	if err != nil {
+		return err
+	}
+	unreachableWider, err := db.GetUnreachableCount(chainID, validatorB, 8)
+	if err != nil {
+		return err
+	}
+	unreachableUnknown, err := db.GetUnreachableCount(chainID, validatorC, 8)
+	if err != nil {
+		return err
+	}
+	return firstError(
+		expect("GetKnownGaps after pruning", gapRanges(remaining), [][2]int{{30, 40}}),
+		expect("GetUnreachableCount of commits 64-69", unreachable, 1),
+		expect("GetUnreachableCount of commits 62-69", unreachableWider, 2),
+		expect("GetUnreachableCount without rows", unreachableUnknown, 0),
+	)
+}
+
+func gapRanges(gaps []db_utils.KnownGap) [][2]int {
+	ranges := [][2]int{}
+	for _, gap := range gaps {
+		ranges = append(ranges, [2]int{gap.FromHeight, gap.ToHeight})
+	}
+	return ranges
+}
+
+func testLeaderboard(db db_utils.Store, chainID string) error {
+	// A commits every height and proposes 10 and 11, B is absent at 9 and 10 and nil-votes at 11, C commits every height
+	for height := 2; height <= 11; height++ {
+		flagB := 2
+		switch height {
+		case 9, 10:
+			flagB = 1
+		case 11:
+			flagB = 3
+		}
+		records := []db_utils.ParticipationRecord{
+			{Address: validatorA, BlockIDFlag: 2, Proposed: height >= 10},
+			{Address: validatorB, BlockIDFlag: flagB},
+			{Address: validatorC, BlockIDFlag: 2},
+		}
+		if err := db.InsertParticipation(chainID, height, height-1, records); err != nil {
+			return err
+		}
+	}
+	// a height already stored is skipped
+	if err := db.InsertParticipation(chainID, 9, 8, []db_utils.ParticipationRecord{{Address: validatorB, BlockIDFlag: 2}}); err != nil {
+		return err
+	}
+
+	// a window of 5 covers the commit heights 6-10 carried by the blocks 7-11
+	leaderboard, err := db.GetLeaderboard(chainID, 5)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard", leaderboard, []db_utils.LeaderboardEntry{
+		{Address: validatorA, Blocks: 5, Committed: 5, Proposed: 2, SigningRate: 1},
+		{Address: validatorC, Blocks: 5, Committed: 5, SigningRate: 1},
+		{Address: validatorB, Blocks: 5, Committed: 2, NilVoted: 1, Absent: 2, SigningRate: 0.4},
+	}); err != nil {
+		return err
+	}
+
+	// keeping 3 heights below block 11 keeps the blocks 9-11
+	if err := db.DeleteOldParticipation(chainID, 3); err != nil {
+		return err
+	}
+	pruned, err := db.GetLeaderboard(chainID, 100)
+	if err != nil {
+		return err
+	}
+	return expect("GetLeaderboard after pruning", pruned, []db_utils.LeaderboardEntry{
+		{Address: validatorA, Blocks: 3, Committed: 3, Proposed: 2, SigningRate: 1},
+		{Address: validatorC, Blocks: 3, Committed: 3, SigningRate: 1},
+		{Address: validatorB, Blocks: 3, Committed: 0, NilVoted: 1, Absent: 2, SigningRate: 0},
+	})
+}
+
+func testBackfillCheckpoints(db db_utils.Store, chainID string) error {
+	_, found, err := db.GetBackfillCheckpoint(chainID, 100, 200)
+	if err != nil {
+		return err
+	}
+	checkpoints := []db_utils.BackfillCheckpoint{
+		{ChainID: chainID, FromHeight: 300, ToHeight: 400, NextHeight: 300},
+		{ChainID: chainID, FromHeight: 100, ToHeight: 200, NextHeight: 100},
+		// done
+		{ChainID: chainID, FromHeight: 50, ToHeight: 60, NextHeight: 61},
+		// progress of the first range
+		{ChainID: chainID, FromHeight: 100, ToHeight: 200, NextHeight: 150},
+	}
+	for _, checkpoint := range checkpoints {
+		if err := db.SaveBackfillCheckpoint(checkpoint); err != nil {
+			return err
+		}
+	}
+
+	checkpoint, ok, err := db.GetBackfillCheckpoint(chainID, 100, 200)
+	if err != nil {
+		return err
+	}
+	done, _, err := db.GetBackfillCheckpoint(chainID, 50, 60)
+	if err != nil {
+		return err
+	}
+	pending, err := db.GetPendingBackfills(chainID)
+	if err != nil {
+		return err
+	}
+	var pendingRanges [][3]int
+	for _, p := range pending {
+		pendingRanges = append(pendingRanges, [3]int{p.FromHeight, p.ToHeight, p.NextHeight})
+	}
+
+	if checkpoint.UpdatedAt == "" {
+		return fmt.Errorf("GetBackfillCheckpoint: UpdatedAt is empty")
+	}
+	return firstError(
+		expect("GetBackfillCheckpoint before the first save", found, false),
+		expect("GetBackfillCheckpoint", []interface{}{ok, checkpoint.ChainID, checkpoint.NextHeight}, []interface{}{true, chainID, 150}),
+		expect("Done", done.Done(), true),
+		expect("GetPendingBackfills", pendingRanges, [][3]int{{100, 200, 150}, {300, 400, 300}}),
+	)
+}
+
+func testPruning(db db_utils.Store, chainID string) error {
+	otherChainID := chainID + "-other"
+	if err := insertSigned(db, otherChainID, 1, 5, validatorA); err != nil {
+		return err
+	}
+	if err := insertSigned(db, chainID, 1, 50, validatorA, validatorB); err != nil {
+		return err
+	}
+	if err := db.DeleteOldRecords(chainID, 10); err != nil {
+		return err
+	}
+
+	minHeight, maxHeight, err := db.GetStoredHeightRange(chainID)
+	if err != nil {
+		return err
+	}
+	records, err := db.GetNumberOfRecordsForChain(chainID)
+	if err != nil {
+		return err
+	}
+	missing, err := db.GetMissingHeights(chainID, validatorB, 41, 50)
+	if err != nil {
+		return err
+	}
+	otherRecords, err := db.GetNumberOfRecordsForChain(otherChainID)
+	if err != nil {
+		return err
+	}
+	// pruning a chain without rows is not an error
+	if err := db.DeleteOldRecords(chainID+"-empty", 10); err != nil {
+		return err
+	}
+
+	return firstError(
+		expect("GetStoredHeightRange", [2]int{minHeight, maxHeight}, [2]int{41, 50}),
+		expect("GetNumberOfRecordsForChain", records, 20),
+		expect("GetMissingHeights", len(missing), 0),
+		expect("GetNumberOfRecordsForChain of another chain", otherRecords, 5),
+	)
+}
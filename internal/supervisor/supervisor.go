@@ -0,0 +1,254 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"cometbftsignrate/internal/api"
+	"cometbftsignrate/internal/logger"
+)
+
+type State string
+
+const (
+	StateRunning  State = "running"
+	StateDegraded State = "degraded"
+	StateStopped  State = "stopped"
+	StateCrashed  State = "crashed"
+)
+
+var states = []State{StateRunning, StateDegraded, StateStopped, StateCrashed}
+
+const (
+	initialRestartDelay = time.Second
+	maxRestartDelay     = 2 * time.Minute
+)
+
+// Worker is a long running task owned by the supervisor, Run must return once ctx is cancelled
+type Worker struct {
+	ChainID string
+	Name    string
+	Run     func(ctx context.Context) error
+}
+
+type WorkerStatus struct {
+	ChainID   string    `json:"chainID"`
+	Name      string    `json:"worker"`
+	State     State     `json:"state"`
+	LastError string    `json:"lastError,omitempty"`
+	Restarts  int       `json:"restarts"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type workerEntry struct {
+	worker Worker
+	status WorkerStatus
+}
+
+// Supervisor runs every worker in its own goroutine, recovers panics and restarts failed workers with backoff
+// so one chain can not take the others down.
+type Supervisor struct {
+	mu      sync.Mutex
+	workers []*workerEntry
+	wg      sync.WaitGroup
+}
+
+func New() *Supervisor {
+	return &Supervisor{}
+}
+
+// permanentError stops a worker without restarting it
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not recoverable by a restart, the worker is moved to the stopped state
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+type entryKey struct{}
+
+// SetDegraded marks the worker running with ctx as degraded, e.g. when a round failed but the worker keeps going
+func SetDegraded(ctx context.Context, err error) {
+	if e, ok := ctx.Value(entryKey{}).(*entryRef); ok {
+		e.supervisor.setState(e.entry, StateDegraded, err)
+	}
+}
+
+// SetHealthy marks the worker running with ctx as running again after it was degraded
+func SetHealthy(ctx context.Context) {
+	if e, ok := ctx.Value(entryKey{}).(*entryRef); ok {
+		e.supervisor.setState(e.entry, StateRunning, nil)
+	}
+}
+
+type entryRef struct {
+	supervisor *Supervisor
+	entry      *workerEntry
+}
+
+// Start launches the worker, it runs until ctx is cancelled or it returns a Permanent error
+func (s *Supervisor) Start(ctx context.Context, worker Worker) {
+	entry := &workerEntry{
+		worker: worker,
+		status: WorkerStatus{ChainID: worker.ChainID, Name: worker.Name},
+	}
+	s.mu.Lock()
+	s.workers = append(s.workers, entry)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.supervise(ctx, entry)
+}
+
+// Wait blocks until every worker has returned
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Supervisor) supervise(ctx context.Context, entry *workerEntry) {
+	defer s.wg.Done()
+
+	workerCtx := context.WithValue(ctx, entryKey{}, &entryRef{supervisor: s, entry: entry})
+	delay := initialRestartDelay
+
+	for {
+		s.mu.Lock()
+		entry.status.StartedAt = time.Now()
+		s.mu.Unlock()
+		s.setState(entry, StateRunning, nil)
+
+		started := time.Now()
+		err := runWorker(workerCtx, entry.worker)
+
+		if ctx.Err() != nil {
+			s.setState(entry, StateStopped, nil)
+			return
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			s.setState(entry, StateStopped, permanent.err)
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("worker returned unexpectedly")
+		}
+		s.setState(entry, StateCrashed, err)
+
+		// a worker that ran for a while before failing starts over with the initial delay
+		if time.Since(started) > maxRestartDelay {
+			delay = initialRestartDelay
+		}
+		logger.PostLog("WARN", logger.ModuleSupervisor{ChainID: entry.worker.ChainID, Worker: entry.worker.Name, State: string(StateCrashed), Message: fmt.Sprintf("Restarting in %s", delay)})
+
+		select {
+		case <-ctx.Done():
+			s.setState(entry, StateStopped, nil)
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+
+		s.mu.Lock()
+		entry.status.Restarts++
+		s.mu.Unlock()
+		api.WorkerRestarts.WithLabelValues(entry.worker.ChainID, entry.worker.Name).Inc()
+	}
+}
+
+// runWorker runs the worker once and turns a panic into an error
+func runWorker(ctx context.Context, worker Worker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.PostLog("ERROR", logger.ModuleSupervisor{ChainID: worker.ChainID, Worker: worker.Name, State: string(StateCrashed), Message: fmt.Sprintf("panic: %v\n%s", r, debug.Stack())})
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return worker.Run(ctx)
+}
+
+func (s *Supervisor) setState(entry *workerEntry, state State, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := entry.status.State
+	entry.status.State = state
+	entry.status.UpdatedAt = time.Now()
+	if err != nil {
+		entry.status.LastError = err.Error()
+	}
+
+	for _, st := range states {
+		value := 0.0
+		if st == state {
+			value = 1
+		}
+		api.WorkerState.WithLabelValues(entry.worker.ChainID, entry.worker.Name, string(st)).Set(value)
+	}
+
+	if previous != state {
+		level := "INFO"
+		if state == StateDegraded || state == StateCrashed {
+			level = "WARN"
+		}
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		logger.PostLog(level, logger.ModuleSupervisor{ChainID: entry.worker.ChainID, Worker: entry.worker.Name, State: string(state), Restarts: entry.status.Restarts, Message: message})
+	}
+}
+
+// Statuses returns the state of every worker ordered by chain and worker name
+func (s *Supervisor) Statuses() []WorkerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]WorkerStatus, 0, len(s.workers))
+	for _, entry := range s.workers {
+		statuses = append(statuses, entry.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ChainID != statuses[j].ChainID {
+			return statuses[i].ChainID < statuses[j].ChainID
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// StatusHandler serves the state of every worker, optionally filtered by the chainID query parameter
+func (s *Supervisor) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	chainID := r.URL.Query().Get("chainID")
+
+	statuses := []WorkerStatus{}
+	for _, status := range s.Statuses() {
+		if chainID == "" || status.ChainID == chainID {
+			statuses = append(statuses, status)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(statuses)
+}